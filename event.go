@@ -11,6 +11,7 @@ var (
 	EventNameNodeChildRemoved     EventName = "astiencoder.node.child.removed"
 	EventNameNodeClosed           EventName = "astiencoder.node.closed"
 	EventNameNodeContinued        EventName = "astiencoder.node.continued"
+	EventNameNodePanicked         EventName = "astiencoder.node.panicked"
 	EventNameNodePaused           EventName = "astiencoder.node.paused"
 	EventNameNodeStarted          EventName = "astiencoder.node.started"
 	EventNameNodeStopped          EventName = "astiencoder.node.stopped"
@@ -26,11 +27,15 @@ var (
 	EventTypeChildRemoved         EventType = "child.removed"
 	EventTypeClosed               EventType = "closed"
 	EventTypeContinued            EventType = "continued"
+	EventTypePanicked             EventType = "panicked"
 	EventTypePaused               EventType = "paused"
 	EventTypeStarted              EventType = "started"
 	EventTypeStopped              EventType = "stopped"
 )
 
+// EventNameNodeResumed is emitted when a paused node is resumed. It's an alias of EventNameNodeContinued
+var EventNameNodeResumed = EventNameNodeContinued
+
 // Event is an event coming out of the encoder
 type Event struct {
 	Name    EventName
@@ -61,6 +66,8 @@ func EventTypeToNodeEventName(eventType EventType) EventName {
 		return EventNameNodeClosed
 	case EventTypeContinued:
 		return EventNameNodeContinued
+	case EventTypePanicked:
+		return EventNameNodePanicked
 	case EventTypePaused:
 		return EventNameNodePaused
 	case EventTypeStarted:
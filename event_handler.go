@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/asticode/go-astikit"
 )
@@ -15,6 +16,7 @@ type EventHandler struct {
 	cs  map[interface{}]map[EventName]map[int]EventCallback
 	idx int
 	m   *sync.Mutex
+	t   *eventHandlerErrorThrottler
 }
 
 // EventCallback represents an event callback
@@ -30,6 +32,10 @@ func NewEventHandler() *EventHandler {
 
 // Add adds a new callback for a specific target and event name
 func (h *EventHandler) Add(target interface{}, eventName EventName, c EventCallback) {
+	h.add(target, eventName, c)
+}
+
+func (h *EventHandler) add(target interface{}, eventName EventName, c EventCallback) int {
 	h.m.Lock()
 	defer h.m.Unlock()
 	if _, ok := h.cs[target]; !ok {
@@ -40,6 +46,7 @@ func (h *EventHandler) Add(target interface{}, eventName EventName, c EventCallb
 	}
 	h.idx++
 	h.cs[target][eventName][h.idx] = c
+	return h.idx
 }
 
 // AddForEventName adds a new callback for a specific event name
@@ -57,6 +64,26 @@ func (h *EventHandler) AddForAll(c EventCallback) {
 	h.Add(nil, "", c)
 }
 
+// Subscribe adds a new handler for a specific event name and returns a function that removes it
+func (h *EventHandler) Subscribe(eventName EventName, handler func(e Event)) (unsubscribe func()) {
+	idx := h.add(nil, eventName, func(e Event) bool {
+		handler(e)
+		return false
+	})
+	return func() { h.del(nil, eventName, idx) }
+}
+
+// SubscribeNode adds a new handler for events whose target is the provided node and returns a
+// function that removes it. Since callbacks are indexed by target, dispatching doesn't scan
+// handlers of other nodes
+func (h *EventHandler) SubscribeNode(n Node, handler func(e Event)) (unsubscribe func()) {
+	idx := h.add(n, "", func(e Event) bool {
+		handler(e)
+		return false
+	})
+	return func() { h.del(n, "", idx) }
+}
+
 func (h *EventHandler) del(target interface{}, eventName EventName, idx int) {
 	h.m.Lock()
 	defer h.m.Unlock()
@@ -120,8 +147,139 @@ func (h *EventHandler) callbacks(target interface{}, eventName EventName) (cs []
 	return
 }
 
-// Emit emits an event
+// ThrottleErrors coalesces identical error events (same message and same target) emitted
+// within period: the first one is emitted right away and, if it has been repeated, a single
+// event whose payload is a *ThrottledError is emitted at the end of period.
+// A period <= 0 disables throttling
+func (h *EventHandler) ThrottleErrors(period time.Duration) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if period <= 0 {
+		h.t = nil
+		return
+	}
+	h.t = newEventHandlerErrorThrottler(h, period)
+}
+
+// ThrottledError represents an error that has been repeated several times
+type ThrottledError struct {
+	Count int
+	Err   error
+}
+
+// Error implements the error interface
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s (repeated %d times)", e.Err, e.Count)
+}
+
+// Unwrap returns the repeated error
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+type eventHandlerErrorThrottler struct {
+	h      *EventHandler
+	is     map[eventHandlerErrorThrottlerKey]*eventHandlerErrorThrottlerItem
+	m      *sync.Mutex // Locks is
+	period time.Duration
+}
+
+type eventHandlerErrorThrottlerKey struct {
+	msg    string
+	target interface{}
+}
+
+type eventHandlerErrorThrottlerItem struct {
+	count int
+	err   error
+}
+
+func newEventHandlerErrorThrottler(h *EventHandler, period time.Duration) *eventHandlerErrorThrottler {
+	return &eventHandlerErrorThrottler{
+		h:      h,
+		is:     make(map[eventHandlerErrorThrottlerKey]*eventHandlerErrorThrottlerItem),
+		m:      &sync.Mutex{},
+		period: period,
+	}
+}
+
+func (t *eventHandlerErrorThrottler) throttle(e Event) (throttled bool) {
+	// Get error
+	err, ok := e.Payload.(error)
+	if !ok {
+		return
+	}
+
+	// Lock
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	// Error has already been emitted during this period
+	k := eventHandlerErrorThrottlerKey{
+		msg:    err.Error(),
+		target: e.Target,
+	}
+	if i, ok := t.is[k]; ok {
+		i.count++
+		i.err = err
+		return true
+	}
+
+	// Store item
+	t.is[k] = &eventHandlerErrorThrottlerItem{}
+
+	// Flush once period is over
+	time.AfterFunc(t.period, func() { t.flush(k) })
+	return
+}
+
+func (t *eventHandlerErrorThrottler) flush(k eventHandlerErrorThrottlerKey) {
+	// Get item
+	t.m.Lock()
+	i, ok := t.is[k]
+	delete(t.is, k)
+	t.m.Unlock()
+
+	// Error has not been repeated
+	if !ok || i.count == 0 {
+		return
+	}
+
+	// Emit
+	t.h.emit(EventError(k.target, &ThrottledError{
+		Count: i.count,
+		Err:   i.err,
+	}))
+}
+
+// Emit emits an event. Callbacks are executed on the caller's goroutine except for throttled
+// errors which are emitted at the end of the throttling period
 func (h *EventHandler) Emit(e Event) {
+	// Throttle errors
+	if e.Name == EventNameError {
+		h.m.Lock()
+		t := h.t
+		h.m.Unlock()
+		if t != nil && t.throttle(e) {
+			return
+		}
+	}
+
+	// Emit
+	h.emit(e)
+}
+
+// EmitSync emits an event and returns once all matching callbacks have been executed on the
+// caller's goroutine. Errors are never throttled.
+// The handler's lock is not held while callbacks are executed, therefore callbacks can emit events
+// and add callbacks. However they must not wait for anything the emitting goroutine is
+// responsible for (e.g. a node's chan when emitting from within this node), otherwise they'll
+// deadlock
+func (h *EventHandler) EmitSync(e Event) {
+	h.emit(e)
+}
+
+func (h *EventHandler) emit(e Event) {
 	for _, c := range h.callbacks(e.Target, e.Name) {
 		if c.c(e) {
 			h.del(c.target, c.eventName, c.idx)
@@ -150,6 +308,8 @@ func (h *EventHandler) Log(o EventHandlerLogOptions) (l *EventLogger) {
 	lls := map[EventName]astikit.LoggerLevel{
 		EventNameError:           astikit.LoggerLevelError,
 		EventNameNodeClosed:      astikit.LoggerLevelInfo,
+		EventNameNodeContinued:   astikit.LoggerLevelInfo,
+		EventNameNodePanicked:    astikit.LoggerLevelError,
 		EventNameNodePaused:      astikit.LoggerLevelInfo,
 		EventNameNodeStarted:     astikit.LoggerLevelInfo,
 		EventNameNodeStopped:     astikit.LoggerLevelInfo,
@@ -182,6 +342,15 @@ func (h *EventHandler) Log(o EventHandlerLogOptions) (l *EventLogger) {
 		l.Writef(lls[e.Name], "astiencoder: node %s (%s) is closed", e.Target.(Node).Metadata().Name, e.Target.(Node).Metadata().Label)
 		return false
 	})
+	h.AddForEventName(EventNameNodeContinued, func(e Event) bool {
+		l.Writef(lls[e.Name], "astiencoder: node %s (%s) is continued", e.Target.(Node).Metadata().Name, e.Target.(Node).Metadata().Label)
+		return false
+	})
+	h.AddForEventName(EventNameNodePanicked, func(e Event) bool {
+		p := e.Payload.(NodePanic)
+		l.Writef(lls[e.Name], "astiencoder: node %s (%s) panicked: %v\n%s", e.Target.(Node).Metadata().Name, e.Target.(Node).Metadata().Label, p.Value, p.Stack)
+		return false
+	})
 	h.AddForEventName(EventNameNodePaused, func(e Event) bool {
 		l.Writef(lls[e.Name], "astiencoder: node %s (%s) is paused", e.Target.(Node).Metadata().Name, e.Target.(Node).Metadata().Label)
 		return false
@@ -1,8 +1,13 @@
 package astiencoder
 
 import (
+	"context"
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/asticode/go-astikit"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,3 +53,117 @@ func TestEventHandler(t *testing.T) {
 	})
 	require.Equal(t, []string{"2", "4", "5"}, es)
 }
+
+func TestEventHandlerSubscribe(t *testing.T) {
+	// Setup
+	eh := NewEventHandler()
+	var es []string
+
+	// Subscribe
+	unsubscribe := eh.Subscribe("test-1", func(evt Event) {
+		es = append(es, "1")
+	})
+	eh.Subscribe("test-2", func(evt Event) {
+		es = append(es, "2")
+	})
+
+	// Emit #1
+	eh.Emit(Event{Name: "test-1"})
+	eh.Emit(Event{Name: "test-2"})
+	require.Equal(t, []string{"1", "2"}, es)
+	es = []string(nil)
+
+	// Emit #2
+	unsubscribe()
+	eh.Emit(Event{Name: "test-1"})
+	eh.Emit(Event{Name: "test-2"})
+	require.Equal(t, []string{"2"}, es)
+}
+
+type mockedNode struct {
+	*BaseNode
+}
+
+func newMockedNode(name string, c *astikit.Closer, eh *EventHandler) (n *mockedNode) {
+	n = &mockedNode{}
+	n.BaseNode = NewBaseNode(NodeOptions{Metadata: NodeMetadata{Name: name}}, c, eh, nil, n, EventTypeToNodeEventName)
+	return
+}
+
+func (n *mockedNode) Start(ctx context.Context, t CreateTaskFunc) {}
+
+func TestEventHandlerSubscribeNode(t *testing.T) {
+	// Setup
+	eh := NewEventHandler()
+	c := astikit.NewCloser()
+	defer c.Close()
+	n1 := newMockedNode("1", c, eh)
+	n2 := newMockedNode("2", c, eh)
+	var es []string
+
+	// Subscribe
+	unsubscribe := eh.SubscribeNode(n1, func(evt Event) {
+		es = append(es, "1")
+	})
+	eh.SubscribeNode(n2, func(evt Event) {
+		es = append(es, "2")
+	})
+
+	// Emit #1
+	eh.Emit(Event{Name: "test", Target: n1})
+	eh.Emit(Event{Name: "test", Target: n2})
+	require.Equal(t, []string{"1", "2"}, es)
+	es = []string(nil)
+
+	// Emit #2
+	unsubscribe()
+	eh.Emit(Event{Name: "test", Target: n1})
+	eh.Emit(Event{Name: "test", Target: n2})
+	require.Equal(t, []string{"2"}, es)
+}
+
+func TestEventHandlerThrottleErrors(t *testing.T) {
+	// Setup
+	eh := NewEventHandler()
+	eh.ThrottleErrors(50 * time.Millisecond)
+	m := &sync.Mutex{}
+	var es []string
+	eh.AddForEventName(EventNameError, func(evt Event) bool {
+		m.Lock()
+		defer m.Unlock()
+		es = append(es, evt.Payload.(error).Error())
+		return false
+	})
+
+	// Emit
+	for i := 0; i < 3; i++ {
+		eh.Emit(EventError("target", errors.New("1")))
+	}
+	eh.Emit(EventError("target", errors.New("2")))
+	m.Lock()
+	require.Equal(t, []string{"1", "2"}, es)
+	m.Unlock()
+
+	// Flush
+	time.Sleep(100 * time.Millisecond)
+	m.Lock()
+	require.Equal(t, []string{"1", "2", "1 (repeated 2 times)"}, es)
+	m.Unlock()
+}
+
+func TestEventHandlerEmitSync(t *testing.T) {
+	// Setup
+	eh := NewEventHandler()
+	eh.ThrottleErrors(time.Hour)
+	var count int
+	eh.AddForEventName(EventNameError, func(evt Event) bool {
+		count++
+		return false
+	})
+
+	// Emit
+	for i := 0; i < 3; i++ {
+		eh.EmitSync(EventError("target", errors.New("1")))
+	}
+	require.Equal(t, 3, count)
+}
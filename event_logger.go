@@ -2,7 +2,9 @@ package astiencoder
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -41,6 +43,80 @@ func MessageMergingEventHandlerLogAdapter(period time.Duration) EventHandlerLogA
 	}
 }
 
+// EventJSON represents an event serialized by JSONEventHandlerLogAdapter
+type EventJSON struct {
+	Name    EventName        `json:"name"`
+	Payload interface{}      `json:"payload,omitempty"`
+	Target  *EventTargetJSON `json:"target,omitempty"`
+	Time    time.Time        `json:"time"`
+}
+
+// EventTargetJSON represents the target of an event serialized by JSONEventHandlerLogAdapter
+type EventTargetJSON struct {
+	Description string   `json:"description,omitempty"`
+	Label       string   `json:"label,omitempty"`
+	Name        string   `json:"name"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// JSONEventHandlerLogAdapter writes each event as a JSON line in w. Messages are not merged.
+// Payloads that can't be serialized are omitted
+func JSONEventHandlerLogAdapter(w io.Writer) EventHandlerLogAdapter {
+	return func(h *EventHandler, l *EventLogger) {
+		m := &sync.Mutex{}
+		h.AddForAll(func(e Event) bool {
+			// Create json event
+			j := EventJSON{
+				Name:    e.Name,
+				Payload: eventPayloadJSON(e),
+				Target:  newEventTargetJSON(e.Target),
+				Time:    time.Now(),
+			}
+
+			// Marshal
+			b, err := json.Marshal(j)
+			if err != nil {
+				j.Payload = nil
+				if b, err = json.Marshal(j); err != nil {
+					return false
+				}
+			}
+
+			// Write
+			m.Lock()
+			defer m.Unlock()
+			w.Write(append(b, '\n')) //nolint:errcheck
+			return false
+		})
+	}
+}
+
+func newEventTargetJSON(i interface{}) *EventTargetJSON {
+	if v, ok := i.(Node); ok {
+		return &EventTargetJSON{
+			Description: v.Metadata().Description,
+			Label:       v.Metadata().Label,
+			Name:        v.Metadata().Name,
+			Tags:        v.Metadata().Tags,
+		}
+	} else if v, ok := i.(*Workflow); ok {
+		return &EventTargetJSON{Name: v.Name()}
+	}
+	return nil
+}
+
+func eventPayloadJSON(e Event) interface{} {
+	switch v := e.Payload.(type) {
+	case error:
+		return v.Error()
+	case Node:
+		return v.Metadata().Name
+	case []EventStat:
+		return newServerStats(e)
+	}
+	return e.Payload
+}
+
 func newEventLogger(i astikit.StdLogger) *EventLogger {
 	return &EventLogger{
 		is: make(map[string]*eventLoggerItem),
@@ -1,7 +1,10 @@
 package astiencoder
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -119,3 +122,23 @@ func TestEventLogger(t *testing.T) {
 	}, ml.msgs)
 	ml.m.Unlock()
 }
+
+func TestJSONEventHandlerLogAdapter(t *testing.T) {
+	eh := NewEventHandler()
+	buf := &bytes.Buffer{}
+	JSONEventHandlerLogAdapter(buf)(eh, nil)
+	eh.Emit(EventError(nil, errors.New("test")))
+	eh.Emit(Event{Name: "test", Payload: map[string]int{"a": 1}})
+	var es []EventJSON
+	d := json.NewDecoder(buf)
+	for d.More() {
+		var e EventJSON
+		require.NoError(t, d.Decode(&e))
+		es = append(es, e)
+	}
+	require.Len(t, es, 2)
+	require.Equal(t, EventNameError, es[0].Name)
+	require.Equal(t, "test", es[0].Payload)
+	require.Equal(t, EventName("test"), es[1].Name)
+	require.Equal(t, map[string]interface{}{"a": float64(1)}, es[1].Payload)
+}
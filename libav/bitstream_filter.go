@@ -0,0 +1,367 @@
+package astilibav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+var countBitstreamFilter uint64
+
+// BitstreamFilter represents an object capable of running a chain of bitstream filters (e.g.
+// h264_mp4toannexb or aac_adtstoasc) over packets without decoding them
+type BitstreamFilter struct {
+	*astiencoder.BaseNode
+	bsfCtxs              []*astiav.BitStreamFilterContext
+	c                    *astikit.Chan
+	d                    *pktDispatcher
+	eh                   *astiencoder.EventHandler
+	eos                  *eosTracker
+	outputCtx            Context
+	p                    *pktPool
+	restamper            PktRestamper
+	statPacketsProcessed uint64
+	statPacketsReceived  uint64
+}
+
+// BitstreamFilterOptions represents bitstream filter options
+type BitstreamFilterOptions struct {
+	// Codec parameters of incoming packets, usually the demuxed stream's ones
+	CodecParameters *astiav.CodecParameters
+	// Dispatcher options, used to choose what happens when a handler can't keep up
+	Dispatcher PktDispatcherOptions
+	// Names of the bitstream filters applied one after the other
+	Filters []string
+	// Timebase of incoming packets
+	InputTimeBase astiav.Rational
+	Node          astiencoder.NodeOptions
+	Restamper     PktRestamper
+}
+
+// NewBitstreamFilter creates a new bitstream filter
+func NewBitstreamFilter(o BitstreamFilterOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (f *BitstreamFilter, err error) {
+	// Check options
+	if len(o.Filters) == 0 {
+		err = errors.New("astilibav: no bitstream filters provided")
+		return
+	}
+	if o.CodecParameters == nil {
+		err = errors.New("astilibav: codec parameters are mandatory")
+		return
+	}
+
+	// Extend node metadata
+	count := atomic.AddUint64(&countBitstreamFilter, uint64(1))
+	o.Node.Metadata = o.Node.Metadata.Extend(fmt.Sprintf("bitstream_filter_%d", count), fmt.Sprintf("Bitstream Filter #%d", count), "Filters bitstream", "bitstream filter")
+
+	// Create bitstream filter
+	f = &BitstreamFilter{
+		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh:        eh,
+		restamper: o.Restamper,
+	}
+
+	// Create base node
+	f.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, f, astiencoder.EventTypeToNodeEventName)
+
+	// Create end of stream tracker
+	f.eos = newEOSTracker(f)
+
+	// Create pkt pool
+	f.p = newPktPool(f)
+
+	// Create pkt dispatcher
+	f.d = newPktDispatcherWithOptions(f, eh, o.Dispatcher)
+
+	// Make sure bitstream filter contexts are properly freed
+	f.AddClose(f.freeBitstreamFilterContexts)
+
+	// Loop through filters
+	cp, tb := o.CodecParameters, o.InputTimeBase
+	for _, n := range o.Filters {
+		// Create bitstream filter context
+		var bsfCtx *astiav.BitStreamFilterContext
+		if bsfCtx, err = newBitstreamFilterContext(n, cp, tb); err != nil {
+			err = fmt.Errorf("astilibav: creating bitstream filter context for %s failed: %w", n, err)
+			return
+		}
+
+		// Append bitstream filter context
+		f.bsfCtxs = append(f.bsfCtxs, bsfCtx)
+
+		// Next filter's input is this filter's output
+		cp, tb = bsfCtx.OutputCodecParameters(), bsfCtx.OutputTimeBase()
+	}
+
+	// Create output ctx
+	f.outputCtx = NewContextFromCodecParameters(cp, tb)
+
+	// Add stat options
+	f.addStatOptions()
+	return
+}
+
+func newBitstreamFilterContext(name string, cp *astiav.CodecParameters, tb astiav.Rational) (bsfCtx *astiav.BitStreamFilterContext, err error) {
+	// Find bitstream filter
+	bsf := astiav.FindBitStreamFilterByName(name)
+	if bsf == nil {
+		err = errors.New("astilibav: bitstream filter not found")
+		return
+	}
+
+	// Alloc bitstream filter context
+	if bsfCtx, err = astiav.AllocBitStreamFilterContext(bsf); err != nil {
+		err = fmt.Errorf("astilibav: allocating bitstream filter context failed: %w", err)
+		return
+	}
+
+	// Make sure bitstream filter context is freed in case of error
+	defer func(err *error) {
+		if *err != nil {
+			bsfCtx.Free()
+			bsfCtx = nil
+		}
+	}(&err)
+
+	// Copy codec parameters
+	if err = cp.Copy(bsfCtx.InputCodecParameters()); err != nil {
+		err = fmt.Errorf("astilibav: copying codec parameters failed: %w", err)
+		return
+	}
+
+	// Set input timebase
+	bsfCtx.SetInputTimeBase(tb)
+
+	// Initialize
+	if err = bsfCtx.Initialize(); err != nil {
+		err = fmt.Errorf("astilibav: initializing bitstream filter context failed: %w", err)
+		return
+	}
+	return
+}
+
+func (f *BitstreamFilter) freeBitstreamFilterContexts() {
+	for _, bsfCtx := range f.bsfCtxs {
+		bsfCtx.Free()
+	}
+	f.bsfCtxs = nil
+}
+
+type BitstreamFilterStats struct {
+	PacketsAllocated  uint64
+	PacketsDispatched uint64
+	PacketsProcessed  uint64
+	PacketsReceived   uint64
+	WorkDuration      time.Duration
+}
+
+func (f *BitstreamFilter) Stats() BitstreamFilterStats {
+	return BitstreamFilterStats{
+		PacketsAllocated:  f.p.stats().packetsAllocated,
+		PacketsDispatched: f.d.stats().packetsDispatched,
+		PacketsProcessed:  atomic.LoadUint64(&f.statPacketsProcessed),
+		PacketsReceived:   atomic.LoadUint64(&f.statPacketsReceived),
+		WorkDuration:      f.c.Stats().WorkDuration,
+	}
+}
+
+func (f *BitstreamFilter) addStatOptions() {
+	// Get stats
+	ss := f.c.StatOptions()
+	ss = append(ss, f.d.statOptions()...)
+	ss = append(ss, f.p.statOptions()...)
+	ss = append(ss,
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets coming in per second",
+				Label:       "Incoming rate",
+				Name:        StatNameIncomingRate,
+				Unit:        "pps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&f.statPacketsReceived),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets processed per second",
+				Label:       "Processed rate",
+				Name:        StatNameProcessedRate,
+				Unit:        "pps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&f.statPacketsProcessed),
+		},
+	)
+
+	// Add stats
+	f.BaseNode.AddStats(ss...)
+}
+
+// OutputCtx returns the output ctx
+func (f *BitstreamFilter) OutputCtx() Context {
+	return f.outputCtx
+}
+
+// OutputCodecParameters returns the codec parameters of outgoing packets, which should be used
+// when adding the muxer stream
+func (f *BitstreamFilter) OutputCodecParameters() *astiav.CodecParameters {
+	return f.bsfCtxs[len(f.bsfCtxs)-1].OutputCodecParameters()
+}
+
+// Connect implements the PktHandlerConnector interface
+func (f *BitstreamFilter) Connect(h PktHandler) {
+	// Add handler
+	f.d.addHandler(h)
+
+	// Connect nodes
+	astiencoder.ConnectNodes(f, h)
+}
+
+// Disconnect implements the PktHandlerConnector interface
+func (f *BitstreamFilter) Disconnect(h PktHandler) {
+	// Delete handler
+	f.d.delHandler(h)
+
+	// Disconnect nodes
+	astiencoder.DisconnectNodes(f, h)
+}
+
+// Start starts the bitstream filter
+func (f *BitstreamFilter) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
+	f.BaseNode.Start(ctx, t, func(t *astikit.Task) {
+		// Make sure to stop the chan properly
+		defer f.c.Stop()
+
+		// Start chan
+		f.c.Start(f.Context())
+
+		// Chan stops once parents are stopped (e.g. when the demuxer has reached eof) and all
+		// pkts have been processed: pkts buffered in the filters need to be drained
+		f.flush()
+	})
+}
+
+func (f *BitstreamFilter) flush() {
+	// No pkt has been processed
+	if atomic.LoadUint64(&f.statPacketsProcessed) == 0 {
+		return
+	}
+
+	// Flush filters one after the other so that pkts drained from a filter go through the next ones
+	// before those are flushed
+	for idx := range f.bsfCtxs {
+		f.filter(idx, nil)
+	}
+}
+
+// HandleEOS implements the EOSHandler interface
+func (f *BitstreamFilter) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !f.eos.add(p.Node) {
+					return
+				}
+
+				// Flush
+				f.flush()
+
+				// Dispatch end of stream
+				f.d.dispatchEOS()
+			})
+		})
+	})
+}
+
+// HandlePkt implements the PktHandler interface
+func (f *BitstreamFilter) HandlePkt(p PktHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Increment received packets
+		atomic.AddUint64(&f.statPacketsReceived, 1)
+
+		// Copy pkt
+		pkt := f.p.get()
+		if err := pkt.Ref(p.Pkt); err != nil {
+			f.p.put(pkt)
+			emitError(f, f.eh, err, "refing packet")
+			return
+		}
+
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Handle pause
+				defer f.HandlePause()
+
+				// Make sure to close pkt
+				defer f.p.put(pkt)
+
+				// Increment processed packets
+				atomic.AddUint64(&f.statPacketsProcessed, 1)
+
+				// Filter
+				f.filter(0, pkt)
+			})
+		})
+	})
+}
+
+// filter sends the pkt to the filter at the provided index and forwards its output to the next
+// filter or, for the last one, dispatches it. A nil pkt flushes the filter
+func (f *BitstreamFilter) filter(idx int, pkt *astiav.Packet) {
+	// Send pkt
+	if err := f.bsfCtxs[idx].SendPacket(pkt); err != nil {
+		if pkt != nil || !errors.Is(err, astiav.ErrEof) {
+			emitError(f, f.eh, err, "sending packet")
+		}
+		return
+	}
+
+	// Loop
+	for {
+		// Receive pkt
+		if stop := f.receivePkt(idx); stop {
+			return
+		}
+	}
+}
+
+func (f *BitstreamFilter) receivePkt(idx int) (stop bool) {
+	// Get pkt
+	pkt := f.p.get()
+	defer f.p.put(pkt)
+
+	// Receive pkt
+	if err := f.bsfCtxs[idx].ReceivePacket(pkt); err != nil {
+		if !errors.Is(err, astiav.ErrEof) && !errors.Is(err, astiav.ErrEagain) {
+			emitError(f, f.eh, err, "receiving packet")
+		}
+		stop = true
+		return
+	}
+
+	// Forward to next filter
+	if idx < len(f.bsfCtxs)-1 {
+		f.filter(idx+1, pkt)
+		return
+	}
+
+	// Restamp
+	if f.restamper != nil {
+		f.restamper.Restamp(pkt)
+	}
+
+	// Dispatch pkt
+	f.d.dispatch(pkt, f.outputCtx.Descriptor())
+	return
+}
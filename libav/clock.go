@@ -0,0 +1,139 @@
+package astilibav
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astikit"
+)
+
+// Clock represents a time source used to emulate rate. It allows driving emulation from an
+// external master clock or advancing time manually in tests
+type Clock interface {
+	Now() time.Time
+	// Sleep blocks until the duration has elapsed or the context is done, in which case the
+	// context error is returned
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type systemClock struct{}
+
+// SystemClock is the default clock, based on the system time
+var SystemClock Clock = systemClock{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	return astikit.Sleep(ctx, d)
+}
+
+func clockOrDefault(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+// SharedClock is a clock shared by several emulators (e.g. FrameRateEmulator nodes of a multi-angle
+// video) so that they schedule against the same origin and don't drift from one another.
+// Use PTSReference to create the emulators' PTS references
+type SharedClock struct {
+	c      Clock
+	m      *sync.Mutex // Locks nudged
+	nudged chan struct{}
+	offset int64
+	origin time.Time
+}
+
+// NewSharedClock creates a new shared clock based on the provided clock, which defaults to
+// SystemClock. Its origin is the current time
+func NewSharedClock(c Clock) *SharedClock {
+	c = clockOrDefault(c)
+	return &SharedClock{
+		c:      c,
+		m:      &sync.Mutex{},
+		nudged: make(chan struct{}),
+		origin: c.Now(),
+	}
+}
+
+// Now implements the Clock interface
+func (c *SharedClock) Now() time.Time {
+	return c.c.Now().Add(time.Duration(atomic.LoadInt64(&c.offset)))
+}
+
+// Sleep implements the Clock interface. Pending sleeps take nudges into account
+func (c *SharedClock) Sleep(ctx context.Context, d time.Duration) error {
+	// Get the time at which sleeping should stop
+	to := c.Now().Add(d)
+
+	// Loop
+	for {
+		// Sleeping is over
+		d = to.Sub(c.Now())
+		if d <= 0 {
+			return nil
+		}
+
+		// Get nudged chan
+		c.m.Lock()
+		nudged := c.nudged
+		c.m.Unlock()
+
+		// Create context cancelled when the clock is nudged
+		nudgeCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-nudged:
+				cancel()
+			case <-nudgeCtx.Done():
+			}
+		}()
+
+		// Sleep
+		err := c.c.Sleep(nudgeCtx, d)
+		cancel()
+
+		// Context is done
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Sleeping is over
+		if err == nil {
+			return nil
+		}
+	}
+}
+
+// Origin returns the time, in the clock's time, all emulators schedule against
+func (c *SharedClock) Origin() time.Time {
+	return c.origin
+}
+
+// PTSReference returns a PTS reference whose time is the clock origin
+func (c *SharedClock) PTSReference(pts int64, timeBase astiav.Rational) PTSReference {
+	return PTSReference{
+		PTS:      pts,
+		Time:     c.origin,
+		TimeBase: timeBase,
+	}
+}
+
+// Nudge shifts the clock by d to correct drift: a positive duration moves the clock forward,
+// therefore emulators dispatch items sooner. Pending sleeps are updated accordingly
+func (c *SharedClock) Nudge(d time.Duration) {
+	// Update offset
+	atomic.AddInt64(&c.offset, int64(d))
+
+	// Wake up sleepers
+	c.m.Lock()
+	defer c.m.Unlock()
+	close(c.nudged)
+	c.nudged = make(chan struct{})
+}
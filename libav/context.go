@@ -1,6 +1,9 @@
 package astilibav
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -39,7 +42,7 @@ type Context struct {
 }
 
 func (ctx Context) Descriptor() Descriptor {
-	return NewDescriptor(ctx.TimeBase)
+	return NewMediaDescriptor(ctx)
 }
 
 func (ctx Context) String() string {
@@ -92,22 +95,132 @@ func (ctx Context) String() string {
 	return strings.Join(ss, " - ")
 }
 
+// Equal returns whether both contexts have the same shared and media type-specific parameters.
+// Index is ignored and dictionaries are compared based on their parsed content
+func (ctx Context) Equal(o Context) bool {
+	return len(ctx.Diff(o)) == 0
+}
+
+// Diff returns human-readable differences between shared and media type-specific parameters
+// of both contexts. Index is ignored and dictionaries are compared based on their parsed content
+func (ctx Context) Diff(o Context) (ds []string) {
+	// Create diff func
+	diff := func(name string, a, b interface{}) {
+		if a != b {
+			ds = append(ds, fmt.Sprintf("%s: %v != %v", name, a, b))
+		}
+	}
+	diffRational := func(name string, a, b astiav.Rational) {
+		if int64(a.Num())*int64(b.Den()) != int64(b.Num())*int64(a.Den()) {
+			ds = append(ds, fmt.Sprintf("%s: %s != %s", name, a, b))
+		}
+	}
+
+	// Shared
+	diff("media type", ctx.MediaType, o.MediaType)
+	diff("bitrate", ctx.BitRate, o.BitRate)
+	diff("codec id", ctx.CodecID, o.CodecID)
+	diff("codec name", ctx.CodecName, o.CodecName)
+	diff("global header", ctx.GlobalHeader, o.GlobalHeader)
+	diffRational("timebase", ctx.TimeBase, o.TimeBase)
+	if !dictionariesAreEqual(ctx.Dictionary, o.Dictionary) {
+		ds = append(ds, "dictionary: contents are different")
+	}
+	if (ctx.ThreadCount == nil) != (o.ThreadCount == nil) || (ctx.ThreadCount != nil && *ctx.ThreadCount != *o.ThreadCount) {
+		ds = append(ds, "thread count: values are different")
+	}
+	if (ctx.ThreadType == nil) != (o.ThreadType == nil) || (ctx.ThreadType != nil && *ctx.ThreadType != *o.ThreadType) {
+		ds = append(ds, "thread type: values are different")
+	}
+
+	// Switch on media type
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		diff("channel layout", ctx.ChannelLayout, o.ChannelLayout)
+		diff("channels", ctx.Channels, o.Channels)
+		diff("frame size", ctx.FrameSize, o.FrameSize)
+		diff("sample fmt", ctx.SampleFormat, o.SampleFormat)
+		diff("sample rate", ctx.SampleRate, o.SampleRate)
+	case astiav.MediaTypeVideo:
+		diffRational("framerate", ctx.FrameRate, o.FrameRate)
+		diff("gop size", ctx.GopSize, o.GopSize)
+		diff("height", ctx.Height, o.Height)
+		diff("pixel format", ctx.PixelFormat, o.PixelFormat)
+		diff("rotation", ctx.Rotation, o.Rotation)
+		diffRational("sample aspect ratio", ctx.SampleAspectRatio, o.SampleAspectRatio)
+		diff("width", ctx.Width, o.Width)
+	}
+	return
+}
+
+// Validate checks that media type-specific mandatory parameters are set
+func (ctx Context) Validate() error {
+	// Switch on media type
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		if ctx.ChannelLayout == 0 {
+			return errors.New("astilibav: audio context has no channel layout")
+		}
+		if ctx.SampleFormat < 0 {
+			return errors.New("astilibav: audio context has no sample format")
+		}
+		if ctx.SampleRate <= 0 {
+			return errors.New("astilibav: audio context has no sample rate")
+		}
+		if ctx.TimeBase.Num() <= 0 || ctx.TimeBase.Den() <= 0 {
+			return errors.New("astilibav: audio context has no timebase")
+		}
+	case astiav.MediaTypeVideo:
+		if ctx.FrameRate.Num() <= 0 || ctx.FrameRate.Den() <= 0 {
+			return errors.New("astilibav: video context has no framerate")
+		}
+		if ctx.Height <= 0 {
+			return errors.New("astilibav: video context has no height")
+		}
+		if ctx.PixelFormat < 0 {
+			return errors.New("astilibav: video context has no pixel format")
+		}
+		if ctx.TimeBase.Num() <= 0 || ctx.TimeBase.Den() <= 0 {
+			return errors.New("astilibav: video context has no timebase")
+		}
+		if ctx.Width <= 0 {
+			return errors.New("astilibav: video context has no width")
+		}
+	default:
+		return fmt.Errorf("astilibav: media type %s is not handled", ctx.MediaType)
+	}
+	return nil
+}
+
 type OutputContexter interface {
 	OutputCtx() Context
 }
 
 func NewContextFromStream(s *astiav.Stream) (ctx Context) {
-	// Get codec parameters
-	cp := s.CodecParameters()
-
 	// Create context
-	ctx = Context{
+	ctx = NewContextFromCodecParameters(s.CodecParameters(), s.TimeBase())
+	ctx.FrameRate = streamFrameRate(s)
+	ctx.Index = s.Index()
+	ctx.SampleAspectRatio = s.SampleAspectRatio()
+
+	// Get display matrix side data
+	if sd := s.SideData(astiav.PacketSideDataTypeDisplaymatrix); len(sd) > 0 {
+		if dm, err := astiav.NewDisplayMatrixFromBytes(sd); err == nil {
+			ctx.Rotation = dm.Rotation()
+		}
+	}
+	return
+}
+
+// NewContextFromCodecParameters creates a context from codec parameters. Since codec parameters
+// don't hold a framerate, it's left empty
+func NewContextFromCodecParameters(cp *astiav.CodecParameters, tb astiav.Rational) Context {
+	return Context{
 		// Shared
 		BitRate:   cp.BitRate(),
 		CodecID:   cp.CodecID(),
-		Index:     s.Index(),
 		MediaType: cp.MediaType(),
-		TimeBase:  s.TimeBase(),
+		TimeBase:  tb,
 
 		// Audio
 		ChannelLayout: cp.ChannelLayout(),
@@ -117,18 +230,35 @@ func NewContextFromStream(s *astiav.Stream) (ctx Context) {
 		SampleRate:    cp.SampleRate(),
 
 		// Video
-		FrameRate:         streamFrameRate(s),
 		Height:            cp.Height(),
 		PixelFormat:       cp.PixelFormat(),
-		SampleAspectRatio: s.SampleAspectRatio(),
+		SampleAspectRatio: cp.SampleAspectRatio(),
 		Width:             cp.Width(),
 	}
+}
 
-	// Get display matrix side data
-	if sd := s.SideData(astiav.PacketSideDataTypeDisplaymatrix); len(sd) > 0 {
-		if dm, err := astiav.NewDisplayMatrixFromBytes(sd); err == nil {
-			ctx.Rotation = dm.Rotation()
-		}
+// Shared and media type-specific parameters of the codec context override the ones of i
+func newContextFromCodecContext(c *astiav.CodecContext, i Context) (ctx Context) {
+	// Create context
+	ctx = i
+	ctx.BitRate = c.BitRate()
+	ctx.CodecID = c.CodecID()
+	ctx.MediaType = c.MediaType()
+
+	// Switch on media type
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		ctx.ChannelLayout = c.ChannelLayout()
+		ctx.Channels = c.Channels()
+		ctx.FrameSize = c.FrameSize()
+		ctx.SampleFormat = c.SampleFormat()
+		ctx.SampleRate = c.SampleRate()
+	case astiav.MediaTypeVideo:
+		ctx.FrameRate = c.Framerate()
+		ctx.Height = c.Height()
+		ctx.PixelFormat = c.PixelFormat()
+		ctx.SampleAspectRatio = c.SampleAspectRatio()
+		ctx.Width = c.Width()
 	}
 	return
 }
@@ -139,3 +269,292 @@ func streamFrameRate(s *astiav.Stream) astiav.Rational {
 	}
 	return s.RFrameRate()
 }
+
+type contextJSON struct {
+	// Shared
+	BitRate      int64           `json:"bit_rate,omitempty"`
+	CodecName    string          `json:"codec_name,omitempty"`
+	CodecID      string          `json:"codec_id,omitempty"`
+	Dictionary   *dictionaryJSON `json:"dictionary,omitempty"`
+	GlobalHeader bool            `json:"global_header,omitempty"`
+	Index        int             `json:"index"`
+	MediaType    string          `json:"media_type"`
+	ThreadCount  *int            `json:"thread_count,omitempty"`
+	ThreadType   *int            `json:"thread_type,omitempty"`
+	TimeBase     string          `json:"time_base,omitempty"`
+
+	// Audio
+	ChannelLayout string `json:"channel_layout,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	FrameSize     int    `json:"frame_size,omitempty"`
+	SampleFormat  string `json:"sample_format,omitempty"`
+	SampleRate    int    `json:"sample_rate,omitempty"`
+
+	// Video
+	FrameRate         string  `json:"frame_rate,omitempty"`
+	GopSize           int     `json:"gop_size,omitempty"`
+	Height            int     `json:"height,omitempty"`
+	PixelFormat       string  `json:"pixel_format,omitempty"`
+	Rotation          float64 `json:"rotation,omitempty"`
+	SampleAspectRatio string  `json:"sample_aspect_ratio,omitempty"`
+	Width             int     `json:"width,omitempty"`
+}
+
+type dictionaryJSON struct {
+	Content   string `json:"content"`
+	Flags     int    `json:"flags,omitempty"`
+	KeyValSep string `json:"key_val_sep"`
+	PairsSep  string `json:"pairs_sep"`
+}
+
+var contextJSONMediaTypes = []astiav.MediaType{
+	astiav.MediaTypeAttachment,
+	astiav.MediaTypeAudio,
+	astiav.MediaTypeData,
+	astiav.MediaTypeSubtitle,
+	astiav.MediaTypeUnknown,
+	astiav.MediaTypeVideo,
+}
+
+// Channel layouts that are marshaled using their name. Other channel layouts are marshaled
+// using their mask
+var contextJSONChannelLayouts = []astiav.ChannelLayout{
+	astiav.ChannelLayoutMono,
+	astiav.ChannelLayoutStereo,
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (ctx Context) MarshalJSON() ([]byte, error) {
+	// Create json context
+	j := contextJSON{
+		// Shared
+		BitRate:      ctx.BitRate,
+		CodecName:    ctx.CodecName,
+		GlobalHeader: ctx.GlobalHeader,
+		Index:        ctx.Index,
+		MediaType:    ctx.MediaType.String(),
+		ThreadCount:  ctx.ThreadCount,
+		TimeBase:     rationalToJSON(ctx.TimeBase),
+
+		// Audio
+		Channels:   ctx.Channels,
+		FrameSize:  ctx.FrameSize,
+		SampleRate: ctx.SampleRate,
+
+		// Video
+		FrameRate:         rationalToJSON(ctx.FrameRate),
+		GopSize:           ctx.GopSize,
+		Height:            ctx.Height,
+		Rotation:          ctx.Rotation,
+		SampleAspectRatio: rationalToJSON(ctx.SampleAspectRatio),
+		Width:             ctx.Width,
+	}
+	if ctx.CodecID > 0 {
+		j.CodecID = ctx.CodecID.Name()
+	}
+	if ctx.Dictionary != nil {
+		j.Dictionary = &dictionaryJSON{
+			Content:   ctx.Dictionary.content,
+			Flags:     int(ctx.Dictionary.flags),
+			KeyValSep: ctx.Dictionary.keyValSep,
+			PairsSep:  ctx.Dictionary.pairsSep,
+		}
+	}
+	if ctx.ThreadType != nil {
+		t := int(*ctx.ThreadType)
+		j.ThreadType = &t
+	}
+
+	// Switch on media type
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		j.ChannelLayout = channelLayoutToJSON(ctx.ChannelLayout)
+		if ctx.SampleFormat >= 0 {
+			j.SampleFormat = ctx.SampleFormat.String()
+		}
+	case astiav.MediaTypeVideo:
+		if ctx.PixelFormat >= 0 {
+			j.PixelFormat = ctx.PixelFormat.String()
+		}
+	}
+
+	// Marshal
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (ctx *Context) UnmarshalJSON(b []byte) (err error) {
+	// Unmarshal
+	var j contextJSON
+	if err = json.Unmarshal(b, &j); err != nil {
+		return
+	}
+
+	// Create context
+	*ctx = Context{
+		// Shared
+		BitRate:      j.BitRate,
+		CodecName:    j.CodecName,
+		GlobalHeader: j.GlobalHeader,
+		Index:        j.Index,
+		ThreadCount:  j.ThreadCount,
+
+		// Audio
+		Channels:     j.Channels,
+		FrameSize:    j.FrameSize,
+		SampleFormat: astiav.SampleFormatNone,
+		SampleRate:   j.SampleRate,
+
+		// Video
+		GopSize:     j.GopSize,
+		Height:      j.Height,
+		PixelFormat: astiav.PixelFormatNone,
+		Rotation:    j.Rotation,
+		Width:       j.Width,
+	}
+
+	// Media type
+	var found bool
+	for _, t := range contextJSONMediaTypes {
+		if t.String() == j.MediaType {
+			ctx.MediaType = t
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = fmt.Errorf("astilibav: invalid media type %s", j.MediaType)
+		return
+	}
+
+	// Codec id
+	if j.CodecID != "" {
+		c := astiav.FindDecoderByName(j.CodecID)
+		if c == nil {
+			c = astiav.FindEncoderByName(j.CodecID)
+		}
+		if c == nil {
+			err = fmt.Errorf("astilibav: invalid codec id %s", j.CodecID)
+			return
+		}
+		ctx.CodecID = c.ID()
+	}
+
+	// Dictionary
+	if j.Dictionary != nil {
+		ctx.Dictionary = NewDictionary(j.Dictionary.Content, j.Dictionary.KeyValSep, j.Dictionary.PairsSep, astiav.DictionaryFlags(j.Dictionary.Flags))
+	}
+
+	// Thread type
+	if j.ThreadType != nil {
+		t := astiav.ThreadType(*j.ThreadType)
+		ctx.ThreadType = &t
+	}
+
+	// Rationals
+	if ctx.FrameRate, err = rationalFromJSON(j.FrameRate); err != nil {
+		err = fmt.Errorf("astilibav: invalid framerate: %w", err)
+		return
+	}
+	if ctx.SampleAspectRatio, err = rationalFromJSON(j.SampleAspectRatio); err != nil {
+		err = fmt.Errorf("astilibav: invalid sample aspect ratio: %w", err)
+		return
+	}
+	if ctx.TimeBase, err = rationalFromJSON(j.TimeBase); err != nil {
+		err = fmt.Errorf("astilibav: invalid timebase: %w", err)
+		return
+	}
+
+	// Channel layout
+	if ctx.ChannelLayout, err = channelLayoutFromJSON(j.ChannelLayout); err != nil {
+		err = fmt.Errorf("astilibav: invalid channel layout: %w", err)
+		return
+	}
+
+	// Pixel format
+	if j.PixelFormat != "" {
+		if ctx.PixelFormat = astiav.FindPixelFormatByName(j.PixelFormat); ctx.PixelFormat < 0 {
+			err = fmt.Errorf("astilibav: invalid pixel format %s", j.PixelFormat)
+			return
+		}
+	}
+
+	// Sample format
+	if j.SampleFormat != "" {
+		if ctx.SampleFormat = astiav.FindSampleFormatByName(j.SampleFormat); ctx.SampleFormat < 0 {
+			err = fmt.Errorf("astilibav: invalid sample format %s", j.SampleFormat)
+			return
+		}
+	}
+	return
+}
+
+func rationalToJSON(r astiav.Rational) string {
+	if r.Num() == 0 && r.Den() == 0 {
+		return ""
+	}
+	return strconv.Itoa(r.Num()) + "/" + strconv.Itoa(r.Den())
+}
+
+func rationalFromJSON(i string) (r astiav.Rational, err error) {
+	// Empty
+	if i == "" {
+		return
+	}
+
+	// Split
+	ps := strings.Split(i, "/")
+	if len(ps) != 2 {
+		err = fmt.Errorf("astilibav: %s is not of the form num/den", i)
+		return
+	}
+
+	// Parse
+	var num, den int
+	if num, err = strconv.Atoi(ps[0]); err != nil {
+		err = fmt.Errorf("astilibav: parsing num failed: %w", err)
+		return
+	}
+	if den, err = strconv.Atoi(ps[1]); err != nil {
+		err = fmt.Errorf("astilibav: parsing den failed: %w", err)
+		return
+	}
+	r = astiav.NewRational(num, den)
+	return
+}
+
+func channelLayoutToJSON(l astiav.ChannelLayout) string {
+	if l == 0 {
+		return ""
+	}
+	for _, v := range contextJSONChannelLayouts {
+		if v == l {
+			return l.String()
+		}
+	}
+	return strconv.FormatUint(uint64(l), 10)
+}
+
+func channelLayoutFromJSON(i string) (l astiav.ChannelLayout, err error) {
+	// Empty
+	if i == "" {
+		return
+	}
+
+	// Known channel layout
+	for _, v := range contextJSONChannelLayouts {
+		if v.String() == i {
+			l = v
+			return
+		}
+	}
+
+	// Parse mask
+	var u uint64
+	if u, err = strconv.ParseUint(i, 10, 64); err != nil {
+		err = fmt.Errorf("astilibav: parsing mask failed: %w", err)
+		return
+	}
+	l = astiav.ChannelLayout(u)
+	return
+}
@@ -20,10 +20,15 @@ type Decoder struct {
 	c                    *astikit.Chan
 	codecCtx             *astiav.CodecContext
 	d                    *frameDispatcher
+	downloadFrames       bool
 	eh                   *astiencoder.EventHandler
+	eos                  *eosTracker
 	fp                   *framePool
+	hardwarePixelFormat  astiav.PixelFormat
+	lastDescriptor       Descriptor
 	outputCtx            Context
 	statBytesReceived    uint64
+	statFramesDropped    uint64
 	statPacketsProcessed uint64
 	statPacketsReceived  uint64
 	pp                   *pktPool
@@ -32,9 +37,30 @@ type Decoder struct {
 // DecoderOptions represents decoder options
 type DecoderOptions struct {
 	CodecParameters *astiav.CodecParameters
-	Name            string
-	Node            astiencoder.NodeOptions
-	OutputCtx       Context
+	// Dispatcher options, used to choose how frames are dispatched to several handlers
+	Dispatcher FrameDispatcherOptions
+	// If true, hardware frames are transferred back to system memory before being dispatched
+	DownloadFrames bool
+	// Device used to create the hardware device context
+	HardwareDeviceName string
+	// If provided, Decoder tries to use hardware acceleration and falls back to software decoding
+	// if the hardware device context can't be created
+	HardwareDeviceType  astiav.HardwareDeviceType
+	HardwarePixelFormat astiav.PixelFormat
+	// If > 0, caps the number of packets buffered by the decoder: once reached, HandlePkt blocks
+	// until a buffered packet has been processed, applying backpressure to the parent
+	MaxBufferedPackets int
+	Name               string
+	Node               astiencoder.NodeOptions
+	// If no codec id is provided, the output ctx is derived from the opened codec context.
+	// In that case its timebase is left empty since decoded frames keep the timebase of
+	// incoming packets
+	OutputCtx Context
+	// Number of frames allocated when the decoder is created, so that latency-sensitive pipelines
+	// don't pay the allocation cost on the first frames
+	PrewarmedFrames int
+	// Number of packets allocated when the decoder is created
+	PrewarmedPackets int
 }
 
 // NewDecoder creates a new decoder
@@ -53,12 +79,17 @@ func NewDecoder(o DecoderOptions, eh *astiencoder.EventHandler, c *astikit.Close
 	// Create base node
 	d.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, d, astiencoder.EventTypeToNodeEventName)
 
+	// Create end of stream tracker
+	d.eos = newEOSTracker(d)
+
 	// Create pools
 	d.fp = newFramePool(d)
-	d.pp = newPktPool(d)
+	d.pp = newBoundedPktPool(d, o.MaxBufferedPackets)
+	d.fp.prewarm(o.PrewarmedFrames)
+	d.pp.prewarm(o.PrewarmedPackets)
 
 	// Create frame dispatcher
-	d.d = newFrameDispatcher(d, eh)
+	d.d = newFrameDispatcherWithOptions(d, eh, o.Dispatcher)
 
 	// Add stat options
 	d.addStatOptions()
@@ -92,18 +123,61 @@ func NewDecoder(o DecoderOptions, eh *astiencoder.EventHandler, c *astikit.Close
 		return
 	}
 
+	// Hardware acceleration
+	if o.HardwareDeviceType != astiav.HardwareDeviceTypeNone {
+		d.setupHardwareAcceleration(o)
+	}
+
 	// Open codec
 	if err = d.codecCtx.Open(codec, nil); err != nil {
 		err = fmt.Errorf("astilibav: opening codec failed: %w", err)
 		return
 	}
+
+	// Derive output ctx
+	if d.outputCtx.CodecID == 0 {
+		d.outputCtx = newContextFromCodecContext(d.codecCtx, o.OutputCtx)
+	}
 	return
 }
 
+func (d *Decoder) setupHardwareAcceleration(o DecoderOptions) {
+	// Create hardware device context
+	hdc, err := astiav.CreateHardwareDeviceContext(o.HardwareDeviceType, o.HardwareDeviceName, nil)
+	if err != nil {
+		// Fall back to software decoding
+		emitError(d, d.eh, err, "creating hardware device context for type %s", o.HardwareDeviceType)
+		return
+	}
+
+	// Make sure the hardware device context is freed
+	d.AddClose(hdc.Free)
+
+	// Update codec context
+	d.codecCtx.SetHardwareDeviceContext(hdc)
+	d.codecCtx.SetPixelFormatCallback(func(pfs []astiav.PixelFormat) astiav.PixelFormat {
+		// Select hardware pixel format
+		for _, pf := range pfs {
+			if pf == o.HardwarePixelFormat {
+				return pf
+			}
+		}
+
+		// Hardware pixel format is not supported
+		emitError(d, d.eh, fmt.Errorf("astilibav: pixel format %s is not supported", o.HardwarePixelFormat), "selecting hardware pixel format")
+		return astiav.PixelFormatNone
+	})
+
+	// Store hardware info
+	d.downloadFrames = o.DownloadFrames
+	d.hardwarePixelFormat = o.HardwarePixelFormat
+}
+
 type DecoderStats struct {
 	BytesReceived    uint64
 	FramesAllocated  uint64
 	FramesDispached  uint64
+	FramesDropped    uint64
 	PacketsAllocated uint64
 	PacketsProcessed uint64
 	PacketsReceived  uint64
@@ -115,6 +189,7 @@ func (d *Decoder) Stats() DecoderStats {
 		BytesReceived:    atomic.LoadUint64(&d.statBytesReceived),
 		FramesAllocated:  d.fp.stats().framesAllocated,
 		FramesDispached:  d.d.stats().framesDispatched,
+		FramesDropped:    atomic.LoadUint64(&d.statFramesDropped),
 		PacketsAllocated: d.pp.stats().packetsAllocated,
 		PacketsProcessed: atomic.LoadUint64(&d.statPacketsProcessed),
 		PacketsReceived:  atomic.LoadUint64(&d.statPacketsReceived),
@@ -147,6 +222,15 @@ func (d *Decoder) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicUint64RateStat(&d.statPacketsProcessed),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames dropped per second because the codec failed to decode them",
+				Label:       "Dropped rate",
+				Name:        StatNameDroppedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&d.statFramesDropped),
+		},
 	)
 
 	// Add stats
@@ -184,6 +268,56 @@ func (d *Decoder) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 
 		// Start chan
 		d.c.Start(d.Context())
+
+		// Chan stops once parents are stopped (e.g. when the demuxer has reached eof) and all
+		// pkts have been processed: frames buffered in the codec need to be drained
+		d.flush()
+	})
+}
+
+func (d *Decoder) flush() {
+	// No pkt has been processed
+	if d.lastDescriptor == nil {
+		return
+	}
+
+	// Send flush pkt to decoder
+	if err := d.codecCtx.SendPacket(nil); err != nil {
+		if !errors.Is(err, astiav.ErrEof) {
+			emitError(d, d.eh, err, "sending flush packet")
+		}
+		return
+	}
+
+	// Loop
+	for {
+		// Receive frame
+		if stop := d.receiveFrame(d.lastDescriptor); stop {
+			return
+		}
+	}
+}
+
+// HandleEOS implements the EOSHandler interface
+func (d *Decoder) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	d.DoWhenUnclosed(func() {
+		// Add to chan
+		d.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			d.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !d.eos.add(p.Node) {
+					return
+				}
+
+				// Flush
+				d.flush()
+
+				// Dispatch end of stream
+				d.d.dispatchEOS()
+			})
+		})
 	})
 }
 
@@ -202,6 +336,7 @@ func (d *Decoder) HandlePkt(p PktHandlerPayload) {
 		// Copy pkt
 		pkt := d.pp.get()
 		if err := pkt.Ref(p.Pkt); err != nil {
+			d.pp.put(pkt)
 			emitError(d, d.eh, err, "refing packet")
 			return
 		}
@@ -219,8 +354,12 @@ func (d *Decoder) HandlePkt(p PktHandlerPayload) {
 				// Increment packets processed
 				atomic.AddUint64(&d.statPacketsProcessed, 1)
 
+				// Store descriptor
+				d.lastDescriptor = p.Descriptor
+
 				// Send pkt to decoder
 				if err := d.codecCtx.SendPacket(pkt); err != nil {
+					atomic.AddUint64(&d.statFramesDropped, 1)
 					emitError(d, d.eh, err, "sending packet")
 					return
 				}
@@ -245,12 +384,33 @@ func (d *Decoder) receiveFrame(descriptor Descriptor) (stop bool) {
 	// Receive frame
 	if err := d.codecCtx.ReceiveFrame(f); err != nil {
 		if !errors.Is(err, astiav.ErrEof) && !errors.Is(err, astiav.ErrEagain) {
+			atomic.AddUint64(&d.statFramesDropped, 1)
 			emitError(d, d.eh, err, "receiving frame")
 		}
 		stop = true
 		return
 	}
 
+	// Download frame
+	if d.downloadFrames && f.PixelFormat() == d.hardwarePixelFormat {
+		// Get frame
+		sf := d.fp.get()
+		defer d.fp.put(sf)
+
+		// Transfer hardware data
+		if err := f.TransferHardwareData(sf); err != nil {
+			emitError(d, d.eh, err, "transferring hardware data")
+			return
+		}
+
+		// Update pts
+		sf.SetPts(f.Pts())
+
+		// Dispatch frame
+		d.d.dispatch(sf, descriptor)
+		return
+	}
+
 	// Dispatch frame
 	d.d.dispatch(f, descriptor)
 	return
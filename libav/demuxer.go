@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,24 +17,40 @@ import (
 )
 
 var (
+	avTimeBaseRational = astiav.NewRational(1, 1e6)
 	countDemuxer       uint64
 	nanosecondRational = astiav.NewRational(1, 1e9)
 )
 
+// ErrDemuxerReadTimeout is the error sent to the read frame error handler when reading a frame
+// took longer than the read timeout
+var ErrDemuxerReadTimeout = errors.New("astilibav: reading frame timed out")
+
 // Demuxer represents an object capable of demuxing packets out of an input
 type Demuxer struct {
 	*astiencoder.BaseNode
 	d                     *pktDispatcher
 	eh                    *astiencoder.EventHandler
+	enforceMonotonicDTS   bool
 	er                    *demuxerEmulateRate
+	fcm                   *sync.Mutex // Locks formatContext
 	formatContext         *astiav.FormatContext
+	ic                    *astikit.Closer
 	interruptRet          *int
 	l                     *demuxerLoop
+	md                    DemuxerMetadata
+	o                     DemuxerOptions
 	p                     *pktPool
 	pb                    *demuxerProbe
+	rc                    *demuxerReconnect
 	readFrameErrorHandler DemuxerReadFrameErrorHandler
+	readTimeout           time.Duration
+	restamper             PktRestamper
+	seeks                 chan *demuxerSeek
 	ss                    map[int]*demuxerStream
+	startAtKeyframe       bool
 	statBytesRead         uint64
+	statStreamBitsRead    map[int]*uint64
 }
 
 // Demuxer will start by dispatching without sleeping all packets with negative PTS
@@ -43,41 +62,155 @@ type DemuxerEmulateRateOptions struct {
 	// start without sleeping.
 	// Defaults to 1s
 	BufferDuration time.Duration
-	Enabled        bool
+	// Defaults to SystemClock
+	Clock   Clock
+	Enabled bool
+	// Speed multiplier applied to the rate: 2 means packets are dispatched twice as fast
+	// as real time.
+	// Defaults to 1
+	Speed float64
 }
 
 type demuxerEmulateRate struct {
 	bufferDuration time.Duration
+	clock          Clock
 	enabled        bool
+	speed          uint64
 }
 
 func newDemuxerEmulateRate(o DemuxerEmulateRateOptions) *demuxerEmulateRate {
 	r := &demuxerEmulateRate{
 		bufferDuration: o.BufferDuration,
+		clock:          clockOrDefault(o.Clock),
 		enabled:        o.Enabled,
 	}
 	if r.bufferDuration <= 0 {
 		r.bufferDuration = time.Second
 	}
+	r.setSpeed(o.Speed)
 	return r
 }
 
+func (r *demuxerEmulateRate) getSpeed() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&r.speed))
+}
+
+func (r *demuxerEmulateRate) setSpeed(speed float64) {
+	if speed <= 0 {
+		speed = 1
+	}
+	atomic.StoreUint64(&r.speed, math.Float64bits(speed))
+}
+
+func (r *demuxerEmulateRate) referenceTime(speed float64) time.Time {
+	return r.clock.Now().Add(-time.Duration(float64(r.bufferDuration) / speed))
+}
+
 // Demuxer will seek back to the start of the input when eof is reached
 // In this case the packets are restamped
 type DemuxerLoopOptions struct {
+	// Maximum number of times the demuxer loops before stopping at eof.
+	// Defaults to 0 which means infinite
+	Count   uint
 	Enabled bool
 }
 
 type demuxerLoop struct {
+	count uint32
 	// Number of time it has looped
 	cycleCount uint
 	// Duration of one loop cycle
 	cycleDuration time.Duration
 	enabled       uint32
+	// Number of times it has looped since it was created. Unlike cycleCount, it's not reset
+	// when seeking
+	looped uint64
+}
+
+// DemuxerLoop is the payload of EventNameDemuxerLooped
+type DemuxerLoop struct {
+	// Number of times the demuxer has looped, including this one
+	Count uint64
+	// Duration of one loop cycle, which is added to timestamps of looped packets
+	CycleDuration time.Duration
 }
 
 func newDemuxerLoop(o DemuxerLoopOptions) *demuxerLoop {
-	return &demuxerLoop{enabled: astikit.BoolToUInt32(o.Enabled)}
+	return &demuxerLoop{
+		count:   uint32(o.Count),
+		enabled: astikit.BoolToUInt32(o.Enabled),
+	}
+}
+
+func (l *demuxerLoop) shouldLoop() bool {
+	// Loop is disabled
+	if atomic.LoadUint32(&l.enabled) == 0 {
+		return false
+	}
+
+	// Loop count is exhausted
+	if c := atomic.LoadUint32(&l.count); c > 0 && l.cycleCount >= uint(c) {
+		return false
+	}
+	return true
+}
+
+// Demuxer will detect timestamp discontinuities (e.g. encoder restart or ad insertion in live inputs)
+// by comparing each packet's DTS against the previous packet's DTS plus its duration. DTS is used
+// rather than PTS since PTS is not monotonic when frames are reordered
+type DemuxerDiscontinuityOptions struct {
+	// If true, packets are restamped so that timestamps remain continuous after a discontinuity
+	Correct bool
+	// Detection is enabled when > 0
+	Threshold time.Duration
+}
+
+// DemuxerDiscontinuity is the payload of EventNameDemuxerDiscontinuity
+type DemuxerDiscontinuity struct {
+	// Whether packets are restamped
+	Corrected bool
+	// Difference between the packet's DTS and the expected DTS
+	Gap    time.Duration
+	Stream *Stream
+}
+
+// Demuxer will close and reopen its input when reading a frame fails with a reconnectable error
+type DemuxerReconnectOptions struct {
+	// Duration to wait before each reconnection attempt
+	Backoff time.Duration
+	Enabled bool
+	// Maximum number of consecutive reconnection attempts.
+	// Defaults to 0 which means infinite
+	MaxAttempts uint
+	// Indicates whether the error is reconnectable.
+	// Defaults to all errors except eof
+	Predicate func(err error) bool
+}
+
+type demuxerReconnect struct {
+	backoff     time.Duration
+	enabled     bool
+	maxAttempts uint
+	predicate   func(err error) bool
+}
+
+func newDemuxerReconnect(o DemuxerReconnectOptions) *demuxerReconnect {
+	r := &demuxerReconnect{
+		backoff:     o.Backoff,
+		enabled:     o.Enabled,
+		maxAttempts: o.MaxAttempts,
+		predicate:   o.Predicate,
+	}
+	if r.predicate == nil {
+		r.predicate = func(err error) bool { return !errors.Is(err, astiav.ErrEof) }
+	}
+	return r
+}
+
+// DemuxerReconnection is the payload of EventNameDemuxerReconnected
+type DemuxerReconnection struct {
+	Attempts uint
+	Err      error
 }
 
 type DemuxerProbeInfo struct {
@@ -100,6 +233,48 @@ func (i DemuxerProbeInfo) PTSReference() PTSReference {
 	}
 }
 
+// DemuxerMetadata represents input container metadata
+type DemuxerMetadata struct {
+	BitRate    int64
+	Duration   time.Duration
+	FormatName string
+	// SMPTE timecode of the first frame read from the "timecode" tag of the first stream having one
+	// or, if none, of the container
+	StartTimecode string
+	// Container-level tags
+	Tags map[string]string
+}
+
+func newDemuxerMetadata(fc *astiav.FormatContext) (m DemuxerMetadata) {
+	// Create metadata
+	m = DemuxerMetadata{
+		BitRate: fc.BitRate(),
+		Tags:    dictionaryToMap(fc.Metadata()),
+	}
+
+	// Duration is expressed in AV_TIME_BASE
+	if v := fc.Duration(); v != astiav.NoPtsValue && v > 0 {
+		m.Duration = time.Duration(astiav.RescaleQ(v, avTimeBaseRational, nanosecondRational))
+	}
+
+	// Format name
+	if f := fc.InputFormat(); f != nil {
+		m.FormatName = f.Name()
+	}
+
+	// Start timecode
+	for _, s := range fc.Streams() {
+		if v := dictionaryToMap(s.Metadata())["timecode"]; v != "" {
+			m.StartTimecode = v
+			break
+		}
+	}
+	if m.StartTimecode == "" {
+		m.StartTimecode = m.Tags["timecode"]
+	}
+	return
+}
+
 type demuxerProbe struct {
 	data     []*astiav.Packet
 	duration time.Duration
@@ -116,13 +291,33 @@ func newDemuxerProbe(duration time.Duration) *demuxerProbe {
 
 type demuxerStreamEmulateRate struct {
 	bufferDuration time.Duration
-	referenceTime  time.Time
+	clock          Clock
+	// Unix nano time at which the last pkt should have been dispatched
+	lastAt        int64
+	referenceTime time.Time
 	// In stream timebase
 	referenceTS int64
+	speed       float64
 }
 
 func (d *Demuxer) newDemuxerStreamEmulateRate(s *demuxerStream) *demuxerStreamEmulateRate {
-	return &demuxerStreamEmulateRate{bufferDuration: d.er.bufferDuration}
+	return &demuxerStreamEmulateRate{
+		bufferDuration: d.er.bufferDuration,
+		clock:          d.er.clock,
+		speed:          d.er.getSpeed(),
+	}
+}
+
+func (r *demuxerStreamEmulateRate) updateSpeed(speed float64, timeBase astiav.Rational) {
+	// Rebase references so that the position at which the speed changes remains the same
+	if !r.referenceTime.IsZero() {
+		n := r.clock.Now()
+		r.referenceTS += astiav.RescaleQ(int64(float64(n.Sub(r.referenceTime))*r.speed), nanosecondRational, timeBase)
+		r.referenceTime = n
+	}
+
+	// Update speed
+	r.speed = speed
 }
 
 type demuxerStreamLoop struct {
@@ -137,12 +332,32 @@ func newDemuxerStreamLoop() *demuxerStreamLoop {
 	return &demuxerStreamLoop{}
 }
 
+type demuxerSeek struct {
+	err         chan error
+	flags       astiav.SeekFlags
+	streamIndex int
+	t           time.Duration
+}
+
 type demuxerStream struct {
-	ctx Context
-	d   Descriptor
-	er  *demuxerStreamEmulateRate
-	l   *demuxerStreamLoop
-	s   *astiav.Stream
+	// ctx and d are only updated in the demuxer's loop, therefore they only need to be locked
+	// when read outside of it
+	ctx          Context
+	d            Descriptor
+	dtsCorrected bool
+	er           *demuxerStreamEmulateRate
+	l            *demuxerStreamLoop
+	m            *sync.Mutex // Locks ctx and d
+	// In stream timebase
+	discontinuityOffset int64
+	// In stream timebase
+	lastDTS *int64
+	// In stream timebase
+	nextDTS *int64
+	// In stream timebase
+	rebaseOffset       int64
+	s                  *astiav.Stream
+	waitingForKeyframe bool
 }
 
 func (d *Demuxer) newDemuxerStream(s *astiav.Stream) *demuxerStream {
@@ -154,46 +369,102 @@ func (d *Demuxer) newDemuxerStream(s *astiav.Stream) *demuxerStream {
 		ctx: ctx,
 		d:   ctx.Descriptor(),
 		l:   newDemuxerStreamLoop(),
+		m:   &sync.Mutex{},
 		s:   s,
 	}
 
 	// Create rate emulator
 	ds.er = d.newDemuxerStreamEmulateRate(ds)
+
+	// Wait for keyframe
+	ds.resetWaitingForKeyframe(d.startAtKeyframe)
 	return ds
 }
 
+func (d *demuxerStream) resetWaitingForKeyframe(startAtKeyframe bool) {
+	// Only video streams are impacted
+	d.waitingForKeyframe = startAtKeyframe && d.ctx.MediaType == astiav.MediaTypeVideo
+}
+
 func (d *demuxerStream) stream() *Stream {
-	return &Stream{
-		CodecParameters: d.s.CodecParameters(),
-		Ctx:             d.ctx,
-		ID:              d.s.ID(),
-		Index:           d.s.Index(),
-	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	return newStream(d.s, d.ctx)
 }
 
 type DemuxerReadFrameErrorHandler func(d *Demuxer, err error) (stop, handled bool)
 
 // DemuxerOptions represents demuxer options
 type DemuxerOptions struct {
+	// If > 0, caps the duration of data analyzed when finding stream info.
+	// See ProbeOptions.AnalyzeDuration
+	AnalyzeDuration time.Duration
 	// String content of the demuxer as you would use in ffmpeg
 	Dictionary *Dictionary
+	// Discontinuity options
+	Discontinuity DemuxerDiscontinuityOptions
+	// If true, end of stream is dispatched to handlers implementing EOSHandler once the end of
+	// the input has been reached, so that nodes flush in order. See EOSHandler.
+	// Since nodes don't expect input after end of stream, Restart shouldn't be used in that case
+	DispatchEOS bool
+	// Dispatcher options, used to choose what happens when a handler can't keep up.
+	// Defaults to blocking the demuxer
+	Dispatcher PktDispatcherOptions
 	// Emulate rate options
 	EmulateRate DemuxerEmulateRateOptions
+	// If true, packets whose DTS is not strictly greater than the previous packet's DTS of the
+	// same stream are restamped. EventNameDemuxerDTSCorrected is emitted the first time it happens
+	// for a stream
+	EnforceMonotonicDTS bool
+	// String content of the options used when finding stream info (e.g. analyzeduration
+	// or probesize) as you would use in ffmpeg
+	FindStreamInfoOptions *Dictionary
 	// Exact input format
 	Format *astiav.InputFormat
 	// Loop options
 	Loop DemuxerLoopOptions
 	// Basic node options
 	Node astiencoder.NodeOptions
-	// Context used to cancel probing
+	// Context used to cancel probing. Since ffmpeg may not check for interruption often, use
+	// AnalyzeDuration and ProbeSize to bound NewDemuxer latency
 	ProbeCtx context.Context
 	// In order to emulate rate or loop properly, Demuxer needs to probe data.
 	// ProbeDuration represents the duration the Demuxer will probe.
 	// Defaults to 1s
 	ProbeDuration time.Duration
+	// If > 0, caps the number of bytes read to detect the input format and find stream info.
+	// See ProbeOptions.ProbeSize
+	ProbeSize int64
 	// Custom read frame error handler
 	// If handled is false, default error handling will be executed
 	ReadFrameErrorHandler DemuxerReadFrameErrorHandler
+	// Reconnect options
+	// Reconnection is attempted before the read frame error handler is called
+	Reconnect DemuxerReconnectOptions
+	// If true and StartOffset is > 0, packets are restamped so that the timeline starts
+	// near zero
+	RebaseToZero bool
+	// If > 0, reading a frame is interrupted once it has taken longer than ReadTimeout
+	// and ErrDemuxerReadTimeout is sent to the read frame error handler
+	ReadTimeout time.Duration
+	// If provided, packets are restamped right before being dispatched
+	Restamper PktRestamper
+	// If true, packets of video streams are dropped until a keyframe is read. This happens
+	// at start and after each seek
+	StartAtKeyframe bool
+	// If provided, it's evaluated once per stream when creating the demuxer and packets of streams
+	// for which it returns false are skipped entirely. Those streams are not returned by Streams()
+	StreamSelector func(s *Stream) bool
+	// If > 0, Demuxer seeks to StartOffset right after finding stream info so that the first
+	// dispatched packet is at or after StartOffset
+	StartOffset time.Duration
+	// If provided, the input is read from the reader instead of URL.
+	// If the reader doesn't implement io.Seeker, the input is considered as non-seekable.
+	Reader io.Reader
+	// If provided, it's called for packets whose stream is not handled by the demuxer (e.g. streams
+	// created after stream info was found or skipped by StreamSelector) instead of silently dropping
+	// them. The packet is only valid during the call: use Ref or Clone to keep it
+	UnknownStreamHandler func(pkt *astiav.Packet)
 	// URL of the input
 	URL string
 }
@@ -207,11 +478,19 @@ func NewDemuxer(o DemuxerOptions, eh *astiencoder.EventHandler, c *astikit.Close
 	// Create demuxer
 	d = &Demuxer{
 		eh:                    eh,
+		enforceMonotonicDTS:   o.EnforceMonotonicDTS,
 		er:                    newDemuxerEmulateRate(o.EmulateRate),
+		fcm:                   &sync.Mutex{},
 		l:                     newDemuxerLoop(o.Loop),
+		o:                     o,
 		pb:                    newDemuxerProbe(o.ProbeDuration),
 		readFrameErrorHandler: o.ReadFrameErrorHandler,
+		readTimeout:           o.ReadTimeout,
+		rc:                    newDemuxerReconnect(o.Reconnect),
+		restamper:             o.Restamper,
+		seeks:                 make(chan *demuxerSeek),
 		ss:                    make(map[int]*demuxerStream),
+		startAtKeyframe:       o.StartAtKeyframe,
 	}
 
 	// Create base node
@@ -221,88 +500,107 @@ func NewDemuxer(o DemuxerOptions, eh *astiencoder.EventHandler, c *astikit.Close
 	d.p = newPktPool(d)
 
 	// Create pkt dispatcher
-	d.d = newPktDispatcher(d, eh)
+	d.d = newPktDispatcherWithOptions(d, eh, o.Dispatcher)
 
 	// Add stat options
 	d.addStatOptions()
 
-	// Dictionary
-	var dict *astiav.Dictionary
-	if o.Dictionary != nil {
-		// Parse dict
-		if dict, err = o.Dictionary.parse(); err != nil {
-			err = fmt.Errorf("astilibav: parsing dict failed: %w", err)
-			return
-		}
+	// Make sure the input is properly closed
+	d.AddCloseWithError(d.closeInput)
 
-		// Make sure the dictionary is freed
-		defer dict.Free()
+	// Open
+	if err = d.open(o.ProbeCtx); err != nil {
+		err = fmt.Errorf("astilibav: opening failed: %w", err)
+		return
 	}
 
-	// Alloc format context
-	d.formatContext = astiav.AllocFormatContext()
+	// Add stream stat options
+	d.addStreamStatOptions()
+	return
+}
 
-	// Make sure the format context is properly freed
-	d.AddClose(d.formatContext.Free)
+func (d *Demuxer) open(probeCtx context.Context) (err error) {
+	// Create input closer
+	d.ic = astikit.NewCloser()
+
+	// Open format context
+	if d.formatContext, d.interruptRet, err = openFormatContext(probeCtx, ProbeOptions{
+		AnalyzeDuration:       d.o.AnalyzeDuration,
+		Dictionary:            d.o.Dictionary,
+		FindStreamInfoOptions: d.o.FindStreamInfoOptions,
+		Format:                d.o.Format,
+		ProbeSize:             d.o.ProbeSize,
+		Reader:                d.o.Reader,
+		URL:                   d.o.URL,
+	}, d.ic); err != nil {
+		err = fmt.Errorf("astilibav: opening format context failed: %w", err)
+		return
+	}
 
-	// Set interrupt callback
-	d.interruptRet = d.formatContext.SetInterruptCallback()
+	// Store metadata
+	d.md = newDemuxerMetadata(d.formatContext)
 
-	// Handle probe cancellation
-	if o.ProbeCtx != nil {
-		// Create context
-		probeCtx, probeCancel := context.WithCancel(o.ProbeCtx)
+	// Create streams
+	d.ss = make(map[int]*demuxerStream)
+	for _, s := range d.formatContext.Streams() {
+		// Create stream
+		ds := d.newDemuxerStream(s)
 
-		// Handle interrupt
-		*d.interruptRet = 0
-		go func() {
-			<-probeCtx.Done()
-			if o.ProbeCtx.Err() != nil {
-				*d.interruptRet = 1
-			}
-		}()
+		// Stream is not selected
+		if d.o.StreamSelector != nil && !d.o.StreamSelector(ds.stream()) {
+			continue
+		}
 
-		// Make sure to cancel context so that go routine is closed
-		defer probeCancel()
+		// Store stream
+		d.ss[s.Index()] = ds
 	}
 
-	// Open input
-	if err = d.formatContext.OpenInput(o.URL, o.Format, dict); err != nil {
-		err = fmt.Errorf("astilibav: opening input failed: %w", err)
-		return
+	// Seek to start offset
+	if d.o.StartOffset > 0 {
+		if err = d.seekToStartOffset(d.o.StartOffset, d.o.RebaseToZero); err != nil {
+			err = fmt.Errorf("astilibav: seeking to start offset failed: %w", err)
+			return
+		}
 	}
 
-	// Make sure the input is properly closed
-	d.AddClose(d.formatContext.CloseInput)
-
-	// Check whether probe has been cancelled
-	if o.ProbeCtx != nil && o.ProbeCtx.Err() != nil {
-		err = fmt.Errorf("astilibav: probing has been cancelled: %w", o.ProbeCtx.Err())
-		return
+	// Probe
+	if d.er.enabled || atomic.LoadUint32(&d.l.enabled) > 0 {
+		if err = d.probe(); err != nil {
+			err = fmt.Errorf("astilibav: probing failed: %w", err)
+			return
+		}
 	}
+	return
+}
 
-	// Find stream information
-	if err = d.formatContext.FindStreamInfo(nil); err != nil {
-		err = fmt.Errorf("astilibav: finding stream info failed: %w", err)
-		return
+func (d *Demuxer) closeInput() error {
+	// Drop probe data
+	for _, pkt := range d.pb.data {
+		d.p.put(pkt)
 	}
+	d.pb.data = []*astiav.Packet{}
 
-	// Check whether probe has been cancelled
-	if o.ProbeCtx != nil && o.ProbeCtx.Err() != nil {
-		err = fmt.Errorf("astilibav: probing has been cancelled: %w", o.ProbeCtx.Err())
-		return
+	// Close
+	return d.ic.Close()
+}
+
+func (d *Demuxer) seekToStartOffset(offset time.Duration, rebaseToZero bool) (err error) {
+	// Get timestamp in AV_TIME_BASE
+	ts := astiav.RescaleQ(int64(offset), nanosecondRational, avTimeBaseRational)
+	if st := d.formatContext.StartTime(); st != astiav.NoPtsValue {
+		ts += st
 	}
 
-	// Create streams
-	for _, s := range d.formatContext.Streams() {
-		d.ss[s.Index()] = d.newDemuxerStream(s)
+	// Seek
+	if err = d.formatContext.SeekFrame(-1, ts, astiav.NewSeekFlags()); err != nil {
+		err = fmt.Errorf("astilibav: seeking to frame failed: %w", err)
+		return
 	}
 
-	// Probe
-	if d.er.enabled || atomic.LoadUint32(&d.l.enabled) > 0 {
-		if err = d.probe(); err != nil {
-			err = fmt.Errorf("astilibav: probing failed: %w", err)
-			return
+	// Update rebase offsets
+	if rebaseToZero {
+		for _, s := range d.ss {
+			s.rebaseOffset = astiav.RescaleQ(ts, avTimeBaseRational, s.ctx.TimeBase)
 		}
 	}
 	return
@@ -412,6 +710,7 @@ type DemuxerStats struct {
 	BytesRead         uint64
 	PacketsAllocated  uint64
 	PacketsDispatched uint64
+	PacketsDropped    uint64
 }
 
 func (d *Demuxer) Stats() DemuxerStats {
@@ -419,6 +718,7 @@ func (d *Demuxer) Stats() DemuxerStats {
 		BytesRead:         atomic.LoadUint64(&d.statBytesRead),
 		PacketsAllocated:  d.p.stats().packetsAllocated,
 		PacketsDispatched: d.d.stats().packetsDispatched,
+		PacketsDropped:    d.d.stats().packetsDropped,
 	}
 }
 
@@ -435,11 +735,80 @@ func (d *Demuxer) addStatOptions() {
 		},
 		Valuer: astikit.NewAtomicUint64RateStat(&d.statBytesRead),
 	})
+	if d.er.enabled {
+		ss = append(ss, astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Maximum duration of packets dispatched ahead of time across streams",
+				Label:       "Emulate rate buffer",
+				Name:        StatNameEmulateRateBuffer,
+				Unit:        "ns",
+			},
+			Valuer: astikit.StatValuerFunc(func(time.Duration) interface{} { return float64(d.emulateRateBuffer()) }),
+		})
+	}
+
+	// Add stats
+	d.BaseNode.AddStats(ss...)
+}
+
+func (d *Demuxer) addStreamStatOptions() {
+	// Sort stream indexes
+	var idxs []int
+	for idx := range d.ss {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+
+	// Loop through stream indexes
+	d.statStreamBitsRead = make(map[int]*uint64)
+	var ss []astikit.StatOptions
+	for _, idx := range idxs {
+		// Create counter
+		v := new(uint64)
+		d.statStreamBitsRead[idx] = v
+
+		// Append stat
+		ss = append(ss, astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: fmt.Sprintf("Number of bits read per second for stream #%d", idx),
+				Label:       fmt.Sprintf("Stream #%d read rate", idx),
+				Name:        fmt.Sprintf("%s.%d", StatNameStreamReadRate, idx),
+				Unit:        "bps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(v),
+		})
+	}
 
 	// Add stats
 	d.BaseNode.AddStats(ss...)
 }
 
+func (d *Demuxer) emulateRateBuffer() (b time.Duration) {
+	for _, s := range d.ss {
+		// Get last at
+		lastAt := atomic.LoadInt64(&s.er.lastAt)
+		if lastAt == 0 {
+			continue
+		}
+
+		// Update buffer
+		if v := time.Unix(0, lastAt).Sub(d.er.clock.Now()); v > b {
+			b = v
+		}
+	}
+	return
+}
+
+// InputMetadata returns the input container metadata
+func (d *Demuxer) InputMetadata() DemuxerMetadata {
+	return d.md
+}
+
+// StartTimecode returns the SMPTE timecode of the first frame, if any
+func (d *Demuxer) StartTimecode() (string, bool) {
+	return d.md.StartTimecode, d.md.StartTimecode != ""
+}
+
 func (d *Demuxer) ProbeInfo() *DemuxerProbeInfo {
 	return d.pb.info
 }
@@ -448,7 +817,25 @@ func (d *Demuxer) SetLoop(loop bool) {
 	atomic.StoreUint32(&d.l.enabled, astikit.BoolToUInt32(loop))
 }
 
-// Streams returns the streams ordered by index
+// SetEmulateRateSpeed sets the emulate rate speed multiplier
+func (d *Demuxer) SetEmulateRateSpeed(speed float64) {
+	d.er.setSpeed(speed)
+}
+
+// LoopCount returns the number of times the demuxer has looped. It's not reset when seeking
+func (d *Demuxer) LoopCount() uint64 {
+	return atomic.LoadUint64(&d.l.looped)
+}
+
+// SetLoopCount sets the maximum number of times the demuxer loops. 0 means infinite
+func (d *Demuxer) SetLoopCount(count uint) {
+	atomic.StoreUint32(&d.l.count, uint32(count))
+}
+
+// Streams returns the streams ordered by index.
+// Their ctx is built once stream info has been found, but some formats only set the definitive
+// stream timebase once the first packets have been read: in that case
+// EventNameDemuxerStreamTimeBaseUpdated is emitted and Streams should be called again
 func (d *Demuxer) Streams() (ss []*Stream) {
 	// Get indexes
 	var idxs []int
@@ -515,19 +902,26 @@ func (d *Demuxer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 		// Update emulate rate time references
 		if d.er.enabled {
 			// Create reference time
-			referenceTime := time.Now().Add(-d.er.bufferDuration)
+			speed := d.er.getSpeed()
+			referenceTime := d.er.referenceTime(speed)
 
 			// Loop through streams
 			for _, s := range d.ss {
 				// Update stream reference time
 				if s.er.referenceTime.IsZero() {
 					s.er.referenceTime = referenceTime
+					s.er.speed = speed
 				}
 			}
 		}
 
 		// Loop
 		for {
+			// Handle seeks
+			d.fcm.Lock()
+			d.handleSeeks()
+			d.fcm.Unlock()
+
 			// Read frame
 			if stop := d.readFrame(); stop {
 				break
@@ -544,6 +938,187 @@ func (d *Demuxer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 	})
 }
 
+// WithFormatContext executes the callback with the underlying format context while no frame is
+// being read. If the read loop is running, it waits for the current read (or reconnection) to be
+// done and is paused until the callback returns, therefore the callback should be fast.
+// The format context must not be used once the callback has returned
+func (d *Demuxer) WithFormatContext(fn func(fc *astiav.FormatContext)) {
+	// Make sure the format context is not freed in the meantime
+	d.DoWhenUnclosed(func() {
+		// Lock
+		d.fcm.Lock()
+		defer d.fcm.Unlock()
+
+		// No format context
+		if d.formatContext == nil {
+			return
+		}
+
+		// Callback
+		fn(d.formatContext)
+	})
+}
+
+// Seek seeks the input to t and makes sure packets read before the seek are not dispatched.
+// If streamIndex is negative, t is rescaled in AV_TIME_BASE instead of the stream timebase.
+// The seek is executed by the read loop, therefore the demuxer needs to be started.
+func (d *Demuxer) Seek(ctx context.Context, t time.Duration, streamIndex int, flags astiav.SeekFlags) error {
+	// Create seek
+	s := &demuxerSeek{
+		err:         make(chan error, 1),
+		flags:       flags,
+		streamIndex: streamIndex,
+		t:           t,
+	}
+
+	// Send seek to the read loop
+	select {
+	case d.seeks <- s:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// Wait for seek to be done
+	select {
+	case err := <-s.err:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Demuxer) handleSeeks() {
+	for {
+		select {
+		case s := <-d.seeks:
+			s.err <- d.seek(s)
+		default:
+			return
+		}
+	}
+}
+
+func (d *Demuxer) seek(s *demuxerSeek) (err error) {
+	// Get timestamp
+	var ts int64
+	if s.streamIndex < 0 {
+		ts = astiav.RescaleQ(int64(s.t), nanosecondRational, avTimeBaseRational)
+	} else {
+		// Get stream
+		ds, ok := d.ss[s.streamIndex]
+		if !ok {
+			err = fmt.Errorf("astilibav: stream %d doesn't exist", s.streamIndex)
+			return
+		}
+		ts = astiav.RescaleQ(int64(s.t), nanosecondRational, ds.ctx.TimeBase)
+	}
+
+	// Seek
+	if err = d.formatContext.SeekFrame(s.streamIndex, ts, s.flags); err != nil {
+		err = fmt.Errorf("astilibav: seeking to frame failed: %w", err)
+		return
+	}
+
+	// Reset state
+	d.resetAfterSeek(s.t)
+	return
+}
+
+func (d *Demuxer) resetAfterSeek(t time.Duration) {
+	// Drop probe data since it has been read before the seek
+	for _, pkt := range d.pb.data {
+		d.p.put(pkt)
+	}
+	d.pb.data = []*astiav.Packet{}
+
+	// Reset loop
+	d.l.cycleCount = 0
+	d.l.cycleDuration = 0
+
+	// Create emulate rate reference time
+	speed := d.er.getSpeed()
+	referenceTime := d.er.referenceTime(speed)
+
+	// Loop through streams
+	for _, ds := range d.ss {
+		// Reset loop
+		ds.l = newDemuxerStreamLoop()
+
+		// Reset last dts
+		ds.lastDTS = nil
+
+		// Reset discontinuity detection
+		ds.discontinuityOffset = 0
+		ds.nextDTS = nil
+
+		// Wait for keyframe
+		ds.resetWaitingForKeyframe(d.startAtKeyframe)
+
+		// Reset emulate rate references
+		ds.er.referenceTime = referenceTime
+		ds.er.speed = speed
+		ds.er.referenceTS = astiav.RescaleQ(int64(t), nanosecondRational, ds.ctx.TimeBase)
+	}
+}
+
+// Restart rewinds the input to its start (or to StartOffset) and starts the read loop again. If
+// rewinding fails, the input is reopened. Handlers and connections are kept.
+// The demuxer must be stopped (e.g. after EOF or an error) and not closed. Since it's a regular
+// BaseNode start, started and stopped events are emitted again. However, children that were stopped
+// automatically when the demuxer stopped are not restarted: either restart them as well or use
+// NodeAutoStopOptions to keep them running
+func (d *Demuxer) Restart(ctx context.Context, t astiencoder.CreateTaskFunc) (err error) {
+	// Node is closed
+	if d.IsClosed() {
+		err = errors.New("astilibav: demuxer is closed")
+		return
+	}
+
+	// Node is not stopped
+	if s := d.Status(); s == astiencoder.StatusRunning || s == astiencoder.StatusPaused {
+		err = fmt.Errorf("astilibav: demuxer is %s", s)
+		return
+	}
+
+	// Lock
+	d.fcm.Lock()
+	defer d.fcm.Unlock()
+
+	// Make sure ffmpeg is not interrupted by the previous read loop's context
+	*d.interruptRet = 0
+
+	// Rewind
+	if errRewind := d.rewind(); errRewind != nil {
+		// Close input
+		if err = d.closeInput(); err != nil {
+			err = fmt.Errorf("astilibav: closing input failed: %w", err)
+			return
+		}
+
+		// Open
+		if err = d.open(ctx); err != nil {
+			err = fmt.Errorf("astilibav: opening failed: %w", err)
+			return
+		}
+	}
+
+	// Start
+	d.Start(ctx, t)
+	return
+}
+
+func (d *Demuxer) rewind() (err error) {
+	// Seek
+	if err = d.seekToStartOffset(d.o.StartOffset, d.o.RebaseToZero && d.o.StartOffset > 0); err != nil {
+		err = fmt.Errorf("astilibav: seeking to start offset failed: %w", err)
+		return
+	}
+
+	// Reset state
+	d.resetAfterSeek(d.o.StartOffset)
+	return
+}
+
 func (d *Demuxer) nextPkt() (pkt *astiav.Packet, handle, stop bool) {
 	// Check probe data first
 	if len(d.pb.data) > 0 {
@@ -557,8 +1132,8 @@ func (d *Demuxer) nextPkt() (pkt *astiav.Packet, handle, stop bool) {
 	pkt = d.p.get()
 
 	// Read frame
-	if err := d.formatContext.ReadFrame(pkt); err != nil {
-		if atomic.LoadUint32(&d.l.enabled) > 0 && errors.Is(err, astiav.ErrEof) {
+	if err := d.readFrameWithTimeout(pkt); err != nil {
+		if errors.Is(err, astiav.ErrEof) && d.l.shouldLoop() {
 			// Loop
 			d.loop()
 
@@ -586,6 +1161,12 @@ func (d *Demuxer) nextPkt() (pkt *astiav.Packet, handle, stop bool) {
 				emitError(d, d.eh, err, "seeking to frame")
 				stop = true
 			}
+		} else if d.rc.enabled && d.Context().Err() == nil && d.rc.predicate(err) {
+			// Reconnect
+			if errReconnect := d.reconnect(err); errReconnect != nil {
+				emitError(d, d.eh, errReconnect, "reconnecting")
+				stop = true
+			}
 		} else {
 			// Custom error handler
 			if d.readFrameErrorHandler != nil {
@@ -598,6 +1179,17 @@ func (d *Demuxer) nextPkt() (pkt *astiav.Packet, handle, stop bool) {
 			// Default error handling
 			if !errors.Is(err, astiav.ErrEof) {
 				emitError(d, d.eh, err, "reading frame")
+			} else if d.Context().Err() == nil {
+				// Emit event
+				d.eh.Emit(astiencoder.Event{
+					Name:   EventNameDemuxerEOF,
+					Target: d,
+				})
+
+				// Dispatch end of stream
+				if d.o.DispatchEOS {
+					d.d.dispatchEOS()
+				}
 			}
 			stop = true
 		}
@@ -609,9 +1201,107 @@ func (d *Demuxer) nextPkt() (pkt *astiav.Packet, handle, stop bool) {
 	return
 }
 
+func (d *Demuxer) reconnect(err error) error {
+	// Loop
+	for attempt := uint(1); d.rc.maxAttempts == 0 || attempt <= d.rc.maxAttempts; attempt++ {
+		// Backoff
+		if d.rc.backoff > 0 {
+			astikit.Sleep(d.Context(), d.rc.backoff) //nolint:errcheck
+		}
+
+		// Check context
+		if d.Context().Err() != nil {
+			return nil
+		}
+
+		// Close input
+		if errClose := d.closeInput(); errClose != nil {
+			emitError(d, d.eh, errClose, "closing input")
+		}
+
+		// Open
+		if errOpen := d.open(d.Context()); errOpen != nil {
+			// Node is stopping
+			if d.Context().Err() != nil {
+				return nil
+			}
+			emitError(d, d.eh, errOpen, "opening")
+			continue
+		}
+
+		// Handle interrupt callback
+		*d.interruptRet = 0
+
+		// Reset loop
+		d.l.cycleCount = 0
+		d.l.cycleDuration = 0
+
+		// Update emulate rate time references
+		if d.er.enabled {
+			// Create reference time
+			speed := d.er.getSpeed()
+			referenceTime := d.er.referenceTime(speed)
+
+			// Loop through streams
+			for _, s := range d.ss {
+				s.er.referenceTime = referenceTime
+				s.er.speed = speed
+			}
+		}
+
+		// Emit event
+		d.eh.Emit(astiencoder.Event{
+			Name: EventNameDemuxerReconnected,
+			Payload: DemuxerReconnection{
+				Attempts: attempt,
+				Err:      err,
+			},
+			Target: d,
+		})
+		return nil
+	}
+	return fmt.Errorf("astilibav: max reconnection attempts reached: %w", err)
+}
+
+func (d *Demuxer) readFrameWithTimeout(pkt *astiav.Packet) (err error) {
+	// No timeout
+	if d.readTimeout <= 0 {
+		return d.formatContext.ReadFrame(pkt)
+	}
+
+	// Interrupt read once timeout is reached
+	var timedOut uint32
+	t := time.AfterFunc(d.readTimeout, func() {
+		atomic.StoreUint32(&timedOut, 1)
+		*d.interruptRet = 1
+	})
+
+	// Read frame
+	err = d.formatContext.ReadFrame(pkt)
+
+	// Stop timer
+	t.Stop()
+
+	// Read has timed out
+	if atomic.LoadUint32(&timedOut) > 0 {
+		// Reset interrupt unless the node is stopping
+		if d.Context().Err() == nil {
+			*d.interruptRet = 0
+		}
+
+		// Update error
+		if err != nil {
+			err = fmt.Errorf("%w after %s", ErrDemuxerReadTimeout, d.readTimeout)
+		}
+	}
+	return
+}
+
 func (d *Demuxer) readFrame() bool {
 	// Get next pkt
+	d.fcm.Lock()
 	pkt, handle, stop := d.nextPkt()
+	d.fcm.Unlock()
 
 	// First, make sure pkt is properly closed
 	defer d.p.put(pkt)
@@ -626,6 +1316,11 @@ func (d *Demuxer) readFrame() bool {
 	// Increment read bytes
 	atomic.AddUint64(&d.statBytesRead, uint64(pkt.Size()))
 
+	// Increment stream read bits
+	if v, ok := d.statStreamBitsRead[pkt.StreamIndex()]; ok {
+		atomic.AddUint64(v, uint64(pkt.Size())*8)
+	}
+
 	// Handle pkt
 	d.handlePkt(pkt)
 	return false
@@ -635,9 +1330,24 @@ func (d *Demuxer) handlePkt(pkt *astiav.Packet) {
 	// Get stream
 	s, ok := d.ss[pkt.StreamIndex()]
 	if !ok {
+		// Custom unknown stream handler
+		if d.o.UnknownStreamHandler != nil {
+			d.o.UnknownStreamHandler(pkt)
+		}
 		return
 	}
 
+	// Refresh timebase
+	d.refreshStreamTimeBase(s)
+
+	// Drop pkts until a keyframe is read
+	if s.waitingForKeyframe {
+		if !pkt.Flags().Has(astiav.PacketFlagKey) {
+			return
+		}
+		s.waitingForKeyframe = false
+	}
+
 	// Timestamps are valid
 	if pkt.Dts() != astiav.NoPtsValue && pkt.Pts() != astiav.NoPtsValue {
 		// Process pkt duration
@@ -697,22 +1407,185 @@ func (d *Demuxer) handlePkt(pkt *astiav.Packet) {
 			pkt.SetPts(pkt.Pts() + dl)
 		}
 
+		// Handle discontinuities
+		// Do it before emulating rate so that a jump doesn't stall the read loop
+		if d.o.Discontinuity.Threshold > 0 {
+			d.handlePktDiscontinuity(pkt, s)
+		}
+
 		// Emulate rate
 		if d.er.enabled {
+			// Speed has changed
+			if speed := d.er.getSpeed(); speed != s.er.speed {
+				s.er.updateSpeed(speed, s.ctx.TimeBase)
+			}
+
 			// Get pkt at
-			pktAt := s.er.referenceTime.Add(time.Duration(astiav.RescaleQ(pkt.Dts()-s.er.referenceTS, s.ctx.TimeBase, nanosecondRational)))
+			pktAt := s.er.referenceTime.Add(time.Duration(float64(astiav.RescaleQ(pkt.Dts()-s.er.referenceTS, s.ctx.TimeBase, nanosecondRational)) / s.er.speed))
+
+			// Store last at
+			atomic.StoreInt64(&s.er.lastAt, pktAt.UnixNano())
 
 			// Wait if there are too many pkts in rate emulator buffer
-			if delta := time.Until(pktAt) - s.er.bufferDuration; delta > 0 {
-				astikit.Sleep(d.Context(), delta) //nolint:errcheck
+			if delta := pktAt.Sub(d.er.clock.Now()) - time.Duration(float64(s.er.bufferDuration)/s.er.speed); delta > 0 {
+				d.er.clock.Sleep(d.Context(), delta) //nolint:errcheck
 			}
 		}
 	}
 
+	// Rebase
+	if s.rebaseOffset != 0 {
+		if pkt.Dts() != astiav.NoPtsValue {
+			pkt.SetDts(pkt.Dts() - s.rebaseOffset)
+		}
+		if pkt.Pts() != astiav.NoPtsValue {
+			pkt.SetPts(pkt.Pts() - s.rebaseOffset)
+		}
+	}
+
+	// Enforce monotonic dts
+	if d.enforceMonotonicDTS {
+		d.enforceMonotonicPktDTS(pkt, s)
+	}
+
+	// Restamp
+	if d.restamper != nil {
+		d.restamper.Restamp(pkt)
+	}
+
 	// Dispatch pkt
 	d.d.dispatch(pkt, s.d)
 }
 
+// DemuxerStreamTimeBaseUpdate is the payload of EventNameDemuxerStreamTimeBaseUpdated
+type DemuxerStreamTimeBaseUpdate struct {
+	Previous astiav.Rational
+	Stream   *Stream
+}
+
+// refreshStreamTimeBase makes sure the stream ctx uses the timebase the format has set on the
+// stream, which may have been updated after stream info has been found
+func (d *Demuxer) refreshStreamTimeBase(s *demuxerStream) {
+	// Timebase has not been updated
+	tb := s.s.TimeBase()
+	if tb.Num() <= 0 || tb.Den() <= 0 || tb == s.ctx.TimeBase {
+		return
+	}
+
+	// Rescale timestamps stored in stream timebase
+	prev := s.ctx.TimeBase
+	rescale := func(i int64) int64 { return astiav.RescaleQ(i, prev, tb) }
+	s.discontinuityOffset = rescale(s.discontinuityOffset)
+	s.rebaseOffset = rescale(s.rebaseOffset)
+	if s.lastDTS != nil {
+		v := rescale(*s.lastDTS)
+		s.lastDTS = &v
+	}
+	if s.nextDTS != nil {
+		v := rescale(*s.nextDTS)
+		s.nextDTS = &v
+	}
+	if s.l.cycleFirstPktPTS != nil {
+		v := rescale(*s.l.cycleFirstPktPTS)
+		s.l.cycleFirstPktPTS = &v
+	}
+	s.l.cycleLastPktPTS = rescale(s.l.cycleLastPktPTS)
+	s.er.referenceTS = rescale(s.er.referenceTS)
+
+	// Update ctx
+	s.m.Lock()
+	s.ctx.TimeBase = tb
+	s.d = s.ctx.Descriptor()
+	s.m.Unlock()
+
+	// Emit event
+	d.eh.Emit(astiencoder.Event{
+		Name: EventNameDemuxerStreamTimeBaseUpdated,
+		Payload: DemuxerStreamTimeBaseUpdate{
+			Previous: prev,
+			Stream:   s.stream(),
+		},
+		Target: d,
+	})
+}
+
+// DemuxerDTSCorrection is the payload of EventNameDemuxerDTSCorrected
+type DemuxerDTSCorrection struct {
+	// In stream timebase
+	Delta  int64
+	Stream *Stream
+}
+
+func (d *Demuxer) enforceMonotonicPktDTS(pkt *astiav.Packet, s *demuxerStream) {
+	// Invalid dts
+	if pkt.Dts() == astiav.NoPtsValue {
+		return
+	}
+
+	// Dts is not strictly increasing
+	if s.lastDTS != nil && pkt.Dts() <= *s.lastDTS {
+		// Get delta
+		delta := *s.lastDTS + 1 - pkt.Dts()
+
+		// Restamp
+		pkt.SetDts(pkt.Dts() + delta)
+		if pkt.Pts() != astiav.NoPtsValue {
+			pkt.SetPts(pkt.Pts() + delta)
+		}
+
+		// Emit event the first time
+		if !s.dtsCorrected {
+			s.dtsCorrected = true
+			d.eh.Emit(astiencoder.Event{
+				Name: EventNameDemuxerDTSCorrected,
+				Payload: DemuxerDTSCorrection{
+					Delta:  delta,
+					Stream: s.stream(),
+				},
+				Target: d,
+			})
+		}
+	}
+
+	// Store last dts
+	s.lastDTS = astikit.Int64Ptr(pkt.Dts())
+}
+
+func (d *Demuxer) handlePktDiscontinuity(pkt *astiav.Packet, s *demuxerStream) {
+	// Apply previous corrections
+	if s.discontinuityOffset != 0 {
+		pkt.SetDts(pkt.Dts() - s.discontinuityOffset)
+		pkt.SetPts(pkt.Pts() - s.discontinuityOffset)
+	}
+
+	// Check gap
+	if s.nextDTS != nil {
+		if gap := time.Duration(astiav.RescaleQ(pkt.Dts()-*s.nextDTS, s.ctx.TimeBase, nanosecondRational)); gap > d.o.Discontinuity.Threshold || gap < -d.o.Discontinuity.Threshold {
+			// Correct
+			if d.o.Discontinuity.Correct {
+				delta := pkt.Dts() - *s.nextDTS
+				s.discontinuityOffset += delta
+				pkt.SetDts(pkt.Dts() - delta)
+				pkt.SetPts(pkt.Pts() - delta)
+			}
+
+			// Emit event
+			d.eh.Emit(astiencoder.Event{
+				Name: EventNameDemuxerDiscontinuity,
+				Payload: DemuxerDiscontinuity{
+					Corrected: d.o.Discontinuity.Correct,
+					Gap:       gap,
+					Stream:    s.stream(),
+				},
+				Target: d,
+			})
+		}
+	}
+
+	// Store next dts
+	s.nextDTS = astikit.Int64Ptr(pkt.Dts() + pkt.Duration())
+}
+
 func (d *Demuxer) processPktSideData(pkt *astiav.Packet, s *demuxerStream) (skippedStart, skippedEnd time.Duration) {
 	// Switch on media type
 	switch s.ctx.MediaType {
@@ -768,4 +1641,15 @@ func (d *Demuxer) loop() {
 
 	// Increment loop cycle count
 	d.l.cycleCount++
+
+	// Emit event
+	// Packets dispatched after this event belong to the new loop cycle
+	d.eh.Emit(astiencoder.Event{
+		Name: EventNameDemuxerLooped,
+		Payload: DemuxerLoop{
+			Count:         atomic.AddUint64(&d.l.looped, 1),
+			CycleDuration: d.l.cycleDuration,
+		},
+		Target: d,
+	})
 }
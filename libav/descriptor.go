@@ -20,3 +20,55 @@ type descriptor struct {
 func (d descriptor) TimeBase() astiav.Rational {
 	return d.timeBase
 }
+
+// MediaDescriptor is a Descriptor that also describes media parameters.
+// Descriptors of dispatched frames always implement it, which allows handlers to detect format
+// changes
+type MediaDescriptor interface {
+	Descriptor
+	Context() Context
+}
+
+func NewMediaDescriptor(ctx Context) MediaDescriptor {
+	return mediaDescriptor{ctx: ctx}
+}
+
+type mediaDescriptor struct {
+	ctx Context
+}
+
+func (d mediaDescriptor) Context() Context {
+	return d.ctx
+}
+
+func (d mediaDescriptor) TimeBase() astiav.Rational {
+	return d.ctx.TimeBase
+}
+
+// Media parameters are read from the frame, other parameters are read from the previous descriptor
+// if it's a MediaDescriptor
+func newFrameMediaDescriptor(f *astiav.Frame, prev Descriptor) MediaDescriptor {
+	// Get previous parameters
+	var ctx Context
+	if v, ok := prev.(MediaDescriptor); ok {
+		ctx = v.Context()
+	}
+	if prev != nil {
+		ctx.TimeBase = prev.TimeBase()
+	}
+
+	// Update media parameters
+	if f.Width() > 0 && f.Height() > 0 {
+		ctx.Height = f.Height()
+		ctx.MediaType = astiav.MediaTypeVideo
+		ctx.PixelFormat = f.PixelFormat()
+		ctx.Width = f.Width()
+	} else if f.SampleRate() > 0 {
+		ctx.ChannelLayout = f.ChannelLayout()
+		ctx.Channels = f.ChannelLayout().NbChannels()
+		ctx.MediaType = astiav.MediaTypeAudio
+		ctx.SampleFormat = f.SampleFormat()
+		ctx.SampleRate = f.SampleRate()
+	}
+	return NewMediaDescriptor(ctx)
+}
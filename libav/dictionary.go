@@ -38,3 +38,51 @@ func (d *Dictionary) parse() (dd *astiav.Dictionary, err error) {
 	}
 	return
 }
+
+func dictionaryToMap(d *astiav.Dictionary) (m map[string]string) {
+	m = make(map[string]string)
+	if d == nil {
+		return
+	}
+	fs := astiav.NewDictionaryFlags(astiav.DictionaryFlagIgnoreSuffix)
+	for e := d.Get("", nil, fs); e != nil; e = d.Get("", e, fs) {
+		m[e.Key()] = e.Value()
+	}
+	return
+}
+
+func dictionariesAreEqual(a, b *Dictionary) bool {
+	// Nil dictionaries
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	// Same content
+	if *a == *b {
+		return true
+	}
+
+	// Parse dictionaries
+	da, err := a.parse()
+	if err != nil {
+		return false
+	}
+	defer da.Free()
+	db, err := b.parse()
+	if err != nil {
+		return false
+	}
+	defer db.Free()
+
+	// Compare maps
+	ma, mb := dictionaryToMap(da), dictionaryToMap(db)
+	if len(ma) != len(mb) {
+		return false
+	}
+	for k, v := range ma {
+		if w, ok := mb[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
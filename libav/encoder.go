@@ -2,8 +2,10 @@ package astilibav
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -21,17 +23,26 @@ type Encoder struct {
 	codecCtx            *astiav.CodecContext
 	d                   *pktDispatcher
 	eh                  *astiencoder.EventHandler
+	eos                 *eosTracker
+	forceKeyframes      []time.Duration
+	forceNextKeyframe   uint32
 	fp                  *framePool
+	outputCtx           Context
 	pp                  *pktPool
 	previousDescriptor  Descriptor
+	statBitsDispatched  uint64
 	statFramesProcessed uint64
 	statFramesReceived  uint64
+	statQuality         uint32
 }
 
 // EncoderOptions represents encoder options
 type EncoderOptions struct {
-	Ctx  Context
-	Node astiencoder.NodeOptions
+	Ctx Context
+	// Video frames whose timestamp is the first to cross one of those boundaries are encoded
+	// as keyframes
+	ForceKeyframes []time.Duration
+	Node           astiencoder.NodeOptions
 }
 
 // NewEncoder creates a new encoder
@@ -42,13 +53,20 @@ func NewEncoder(o EncoderOptions, eh *astiencoder.EventHandler, c *astikit.Close
 
 	// Create encoder
 	e = &Encoder{
-		c:  astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
-		eh: eh,
+		c:              astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh:             eh,
+		forceKeyframes: append([]time.Duration{}, o.ForceKeyframes...),
 	}
 
+	// Sort forced keyframes
+	sort.Slice(e.forceKeyframes, func(i, j int) bool { return e.forceKeyframes[i] < e.forceKeyframes[j] })
+
 	// Create base node
 	e.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, e, astiencoder.EventTypeToNodeEventName)
 
+	// Create end of stream tracker
+	e.eos = newEOSTracker(e)
+
 	// Create pools
 	e.fp = newFramePool(e)
 	e.pp = newPktPool(e)
@@ -59,6 +77,12 @@ func NewEncoder(o EncoderOptions, eh *astiencoder.EventHandler, c *astikit.Close
 	// Add stat options
 	e.addStatOptions()
 
+	// Validate context
+	if err = o.Ctx.Validate(); err != nil {
+		err = fmt.Errorf("astilibav: validating context failed: %w", err)
+		return
+	}
+
 	// Find encoder
 	var codec *astiav.Codec
 	if len(o.Ctx.CodecName) > 0 {
@@ -136,25 +160,33 @@ func NewEncoder(o EncoderOptions, eh *astiencoder.EventHandler, c *astikit.Close
 		err = fmt.Errorf("astilibav: opening codec failed: %w", err)
 		return
 	}
+
+	// Create output ctx based on the negotiated codec context
+	e.outputCtx = newContextFromCodecContext(e.codecCtx, o.Ctx)
+	e.outputCtx.TimeBase = e.codecCtx.TimeBase()
 	return
 }
 
 type EncoderStats struct {
+	BitsDispatched   uint64
 	FramesAllocated  uint64
 	FramesProcessed  uint64
 	FramesReceived   uint64
 	PacketsAllocated uint64
 	PacketsDispached uint64
+	Quantizer        float64
 	WorkDuration     time.Duration
 }
 
 func (e *Encoder) Stats() EncoderStats {
 	return EncoderStats{
+		BitsDispatched:   atomic.LoadUint64(&e.statBitsDispatched),
 		FramesAllocated:  e.fp.stats().framesAllocated,
 		FramesProcessed:  atomic.LoadUint64(&e.statFramesProcessed),
 		FramesReceived:   atomic.LoadUint64(&e.statFramesReceived),
 		PacketsAllocated: e.pp.stats().packetsAllocated,
 		PacketsDispached: e.d.stats().packetsDispatched,
+		Quantizer:        e.quantizer(),
 		WorkDuration:     e.c.Stats().WorkDuration,
 	}
 }
@@ -184,12 +216,35 @@ func (e *Encoder) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicUint64RateStat(&e.statFramesProcessed),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of bits dispatched per second",
+				Label:       "Bitrate",
+				Name:        StatNameBitrate,
+				Unit:        "bps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&e.statBitsDispatched),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Quantizer of the last encoded frame, as reported by the codec",
+				Label:       "Quantizer",
+				Name:        StatNameQuantizer,
+				Unit:        "qp",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return e.quantizer() }),
+		},
 	)
 
 	// Add stats
 	e.BaseNode.AddStats(ss...)
 }
 
+// OutputCtx returns the output ctx
+func (e *Encoder) OutputCtx() Context {
+	return e.outputCtx
+}
+
 // Connect implements the PktHandlerConnector interface
 func (e *Encoder) Connect(h PktHandler) {
 	// Add handler
@@ -226,6 +281,29 @@ func (e *Encoder) flush() {
 	e.encode(nil, nil)
 }
 
+// HandleEOS implements the EOSHandler interface
+func (e *Encoder) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	e.DoWhenUnclosed(func() {
+		// Add to chan
+		e.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			e.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !e.eos.add(p.Node) {
+					return
+				}
+
+				// Flush
+				e.flush()
+
+				// Dispatch end of stream
+				e.d.dispatchEOS()
+			})
+		})
+	})
+}
+
 // HandleFrame implements the FrameHandler interface
 func (e *Encoder) HandleFrame(p FrameHandlerPayload) {
 	// Everything executed outside the main loop should be protected from the closer
@@ -267,12 +345,20 @@ func (e *Encoder) encode(f *astiav.Frame, d Descriptor) {
 		case astiav.MediaTypeVideo:
 			f.SetKeyFrame(false)
 			f.SetPictureType(astiav.PictureTypeNone)
+
+			// Force keyframe
+			if e.shouldForceKeyframe(f, d) {
+				f.SetPictureType(astiav.PictureTypeI)
+			}
 		}
 	}
 
 	// Send frame to encoder
+	// Encoder may have already been flushed once its parents have reached end of stream
 	if err := e.codecCtx.SendFrame(f); err != nil {
-		emitError(e, e.eh, err, "sending frame")
+		if f != nil || !errors.Is(err, astiav.ErrEof) {
+			emitError(e, e.eh, err, "sending frame")
+		}
 		return
 	}
 
@@ -285,6 +371,31 @@ func (e *Encoder) encode(f *astiav.Frame, d Descriptor) {
 	}
 }
 
+func (e *Encoder) shouldForceKeyframe(f *astiav.Frame, d Descriptor) (force bool) {
+	// Next keyframe has been forced
+	if atomic.CompareAndSwapUint32(&e.forceNextKeyframe, 1, 0) {
+		force = true
+	}
+
+	// No boundaries left or invalid timestamp
+	if len(e.forceKeyframes) == 0 || f.Pts() == astiav.NoPtsValue || d == nil {
+		return
+	}
+
+	// Remove boundaries crossed by the frame
+	t := time.Duration(astiav.RescaleQ(f.Pts(), d.TimeBase(), nanosecondRational))
+	for len(e.forceKeyframes) > 0 && e.forceKeyframes[0] <= t {
+		e.forceKeyframes = e.forceKeyframes[1:]
+		force = true
+	}
+	return
+}
+
+// ForceNextKeyframe makes sure the next video frame is encoded as a keyframe
+func (e *Encoder) ForceNextKeyframe() {
+	atomic.StoreUint32(&e.forceNextKeyframe, 1)
+}
+
 func (e *Encoder) receivePkt(d Descriptor) (stop bool) {
 	// Get pkt from pool
 	pkt := e.pp.get()
@@ -317,11 +428,26 @@ func (e *Encoder) receivePkt(d Descriptor) (stop bool) {
 	// Rescale timestamps
 	pkt.RescaleTs(d.TimeBase(), e.codecCtx.TimeBase())
 
+	// Update codec stats
+	atomic.AddUint64(&e.statBitsDispatched, uint64(pkt.Size())*8)
+	if b := pkt.SideData(astiav.PacketSideDataTypeQualityStats); len(b) >= 4 {
+		atomic.StoreUint32(&e.statQuality, binary.LittleEndian.Uint32(b))
+	}
+
 	// Dispatch pkt
 	e.d.dispatch(pkt, newEncoderDescriptor(e.codecCtx))
 	return
 }
 
+// Codecs report quality as a lambda value in the packet quality stats side data
+const encoderQP2Lambda = 118
+
+// quantizer returns the quantizer of the last encoded frame. It is 0 if the codec doesn't
+// report quality stats
+func (e *Encoder) quantizer() float64 {
+	return float64(atomic.LoadUint32(&e.statQuality)) / encoderQP2Lambda
+}
+
 // AddStream adds a stream based on the codec ctx
 func (e *Encoder) AddStream(formatCtx *astiav.FormatContext) (o *astiav.Stream, err error) {
 	// Add stream
@@ -338,6 +464,60 @@ func (e *Encoder) AddStream(formatCtx *astiav.FormatContext) (o *astiav.Stream,
 	return
 }
 
+// EncoderBitRateUpdate is the payload of EventNameEncoderBitRateUpdated
+type EncoderBitRateUpdate struct {
+	New int64
+	Old int64
+}
+
+// SetBitRate updates the target bit rate of the encoder. Rate control max rate and buffer size
+// are updated proportionally if they were set.
+// The update is executed in the encoder's loop, EventNameEncoderBitRateUpdated is emitted once
+// it's done.
+// Only codecs that check their codec context bit rate between frames (e.g. libx264) take the new
+// value into account on the fly, other codecs (e.g. libx265, libvpx, aac) need to be reopened,
+// which means a new encoder needs to be created.
+func (e *Encoder) SetBitRate(bitRate int64) {
+	// Invalid bit rate
+	if bitRate <= 0 {
+		emitError(e, e.eh, fmt.Errorf("astilibav: invalid bit rate %d", bitRate), "setting bit rate")
+		return
+	}
+
+	// Add to chan
+	e.c.Add(func() {
+		// Everything executed outside the main loop should be protected from the closer
+		e.DoWhenUnclosed(func() {
+			// Get old bit rate
+			old := e.codecCtx.BitRate()
+
+			// Update rate control parameters
+			if old > 0 {
+				ratio := float64(bitRate) / float64(old)
+				if v := e.codecCtx.RateControlMaxRate(); v > 0 {
+					e.codecCtx.SetRateControlMaxRate(int64(float64(v) * ratio))
+				}
+				if v := e.codecCtx.RateControlBufferSize(); v > 0 {
+					e.codecCtx.SetRateControlBufferSize(int(float64(v) * ratio))
+				}
+			}
+
+			// Update bit rate
+			e.codecCtx.SetBitRate(bitRate)
+
+			// Emit event
+			e.eh.Emit(astiencoder.Event{
+				Name: EventNameEncoderBitRateUpdated,
+				Payload: EncoderBitRateUpdate{
+					New: bitRate,
+					Old: old,
+				},
+				Target: e,
+			})
+		})
+	})
+}
+
 // FrameSize returns the encoder frame size
 func (e *Encoder) FrameSize() int {
 	return e.codecCtx.FrameSize()
@@ -0,0 +1,73 @@
+package astilibav
+
+import (
+	"sync"
+
+	"github.com/asticode/go-astiencoder"
+)
+
+// EOSHandler represents a node that needs to know when a parent won't send anything else (end of
+// stream).
+//
+// End of stream is dispatched by the demuxer once it has reached the end of its input (see
+// DemuxerOptions.DispatchEOS) and is handled in order with incoming packets and frames. Once all
+// its parents have reached end of stream, a node flushes its internal buffers (e.g. frames
+// buffered in a codec or in a filter graph) and dispatches end of stream to its own handlers, so
+// that flushes happen in order from the demuxer down to the muxer, which then writes its trailer.
+//
+// Nodes don't expect input anymore once they have handled end of stream.
+// Nodes that don't implement EOSHandler don't forward end of stream, in which case their handlers
+// are only flushed once they're stopped
+type EOSHandler interface {
+	HandleEOS(p EOSHandlerPayload)
+}
+
+// EOSHandlerPayload represents an EOSHandler payload
+type EOSHandlerPayload struct {
+	Node astiencoder.Node
+}
+
+// eosTracker keeps track of parents that have reached end of stream
+type eosTracker struct {
+	done    bool
+	m       *sync.Mutex // Locks done and parents
+	n       astiencoder.Node
+	parents map[string]bool
+}
+
+func newEOSTracker(n astiencoder.Node) *eosTracker {
+	return &eosTracker{
+		m:       &sync.Mutex{},
+		n:       n,
+		parents: make(map[string]bool),
+	}
+}
+
+// add returns true the first time all parents have reached end of stream
+func (t *eosTracker) add(parent astiencoder.Node) bool {
+	// Lock
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	// End of stream has already been reached
+	if t.done {
+		return false
+	}
+
+	// Store parent
+	if parent != nil {
+		t.parents[parent.Metadata().Name] = true
+	}
+
+	// Loop through parents
+	for _, p := range t.n.Parents() {
+		// Parent has not reached end of stream
+		if !t.parents[p.Metadata().Name] {
+			return false
+		}
+	}
+
+	// End of stream has been reached
+	t.done = true
+	return true
+}
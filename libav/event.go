@@ -2,20 +2,53 @@ package astilibav
 
 // Event names
 const (
-	EventNameLog = "astilibav.log"
+	// Demuxer has restamped a non monotonic DTS for the first time for a stream
+	EventNameDemuxerDTSCorrected = "astilibav.demuxer.dts.corrected"
+	// Demuxer has detected a timestamp gap bigger than the discontinuity threshold
+	EventNameDemuxerDiscontinuity = "astilibav.demuxer.discontinuity"
+	// Demuxer has reached the end of its input and is not looping
+	EventNameDemuxerEOF = "astilibav.demuxer.eof"
+	// Demuxer has reached the end of its input and is seeking back to its start
+	EventNameDemuxerLooped = "astilibav.demuxer.looped"
+	// Demuxer has reopened its input after a reconnectable error
+	EventNameDemuxerReconnected = "astilibav.demuxer.reconnected"
+	// Format has updated the timebase of a demuxer stream after stream info has been found.
+	// Packets dispatched after this event are in the new timebase
+	EventNameDemuxerStreamTimeBaseUpdated = "astilibav.demuxer.stream.time.base.updated"
+	// Encoder bit rate has been updated
+	EventNameEncoderBitRateUpdated = "astilibav.encoder.bit.rate.updated"
+	// Filterer graph has been rebuilt since an input format has changed
+	EventNameFiltererReconfigured = "astilibav.filterer.reconfigured"
+	EventNameLog                  = "astilibav.log"
+	// Transition to a new node has started in the rate enforcer
+	EventNameRateEnforcerSwitchedIn = "astilibav.rate.enforcer.switched.in"
 	// First frame of new node has been dispatched by the rate enforcer
 	EventNameRateEnforcerSwitchedOut = "astilibav.rate.enforcer.switched.out"
+	// Scaler has recreated its scale context since the input format has changed
+	EventNameScalerReconfigured = "astilibav.scaler.reconfigured"
 )
 
 // Stat names
 const (
-	StatNameAllocatedFrames  = "astilibav.allocated.frames"
-	StatNameAllocatedPackets = "astilibav.allocated.packets"
-	StatNameAverageDelay     = "astilibav.average.delay"
-	StatNameFilledRate       = "astilibav.filled.rate"
-	StatNameIncomingRate     = "astilibav.incoming.rate"
-	StatNameOutgoingRate     = "astilibav.outgoing.rate"
-	StatNameProcessedRate    = "astilibav.processed.rate"
-	StatNameReadRate         = "astilibav.read.rate"
-	StatNameWrittenRate      = "astilibav.written.rate"
+	StatNameAllocatedFrames   = "astilibav.allocated.frames"
+	StatNameAllocatedPackets  = "astilibav.allocated.packets"
+	StatNameAverageDelay      = "astilibav.average.delay"
+	StatNameDelayP50          = "astilibav.delay.p50"
+	StatNameDelayP95          = "astilibav.delay.p95"
+	StatNameDelayP99          = "astilibav.delay.p99"
+	StatNameDroppedRate       = "astilibav.dropped.rate"
+	StatNameBufferedFrames    = "astilibav.buffered.frames"
+	StatNameEmulateRateBuffer = "astilibav.emulate.rate.buffer"
+	StatNameFilledRate        = "astilibav.filled.rate"
+	StatNameBitrate           = "astilibav.bitrate"
+	StatNameIncomingRate      = "astilibav.incoming.rate"
+	StatNameOutgoingRate      = "astilibav.outgoing.rate"
+	StatNameProcessedRate     = "astilibav.processed.rate"
+	StatNameQuantizer         = "astilibav.quantizer"
+	StatNameReadRate          = "astilibav.read.rate"
+	StatNameSlotOccupancy     = "astilibav.slot.occupancy"
+	StatNameStreamReadRate    = "astilibav.stream.read.rate"
+	StatNameUsedFrames        = "astilibav.used.frames"
+	StatNameUsedPackets       = "astilibav.used.packets"
+	StatNameWrittenRate       = "astilibav.written.rate"
 )
@@ -16,30 +16,45 @@ import (
 var countFilterer uint64
 
 // Filterer represents an object capable of applying a filter to frames
+//
+// If an input frame's format differs from its input's current format, the graph is rebuilt using
+// the same filter content and EventNameFiltererReconfigured is emitted
 type Filterer struct {
 	*astiencoder.BaseNode
 	buffersinkContext   *astiav.FilterContext
-	buffersrcContexts   map[astiencoder.Node][]*astiav.FilterContext
+	buffersrcContexts   map[string]*astiav.FilterContext
 	c                   *astikit.Chan
+	content             string
 	d                   *frameDispatcher
 	eh                  *astiencoder.EventHandler
 	emulatePeriod       time.Duration
+	eos                 *eosTracker
 	g                   *astiav.FilterGraph
+	gc                  *astikit.Closer
+	inputCtxs           map[string]Context
+	inputNames          map[astiencoder.Node][]string
 	outputCtx           Context
 	p                   *framePool
 	restamper           FrameRestamper
+	sq                  *filtererSyncQueue
 	statFramesProcessed uint64
 	statFramesReceived  uint64
 }
 
 // FiltererOptions represents filterer options
 type FiltererOptions struct {
-	Content     string
+	Content string
+	// Dispatcher options, used to choose how frames are dispatched to several handlers
+	Dispatcher  FrameDispatcherOptions
 	EmulateRate astiav.Rational
-	Inputs      map[string]astiencoder.Node
-	Node        astiencoder.NodeOptions
-	OutputCtx   Context
-	Restamper   FrameRestamper
+	// Indexed by input name as used in the filter content
+	Inputs    map[string]astiencoder.Node
+	Node      astiencoder.NodeOptions
+	OutputCtx Context
+	Restamper FrameRestamper
+	// If true and there are several inputs, frames are buffered per input and fed to the graph
+	// in PTS order once each input has at least one frame buffered
+	SyncInputs bool
 }
 
 // NewFilterer creates a new filterer
@@ -50,21 +65,32 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 
 	// Create filterer
 	f = &Filterer{
-		buffersrcContexts: make(map[astiencoder.Node][]*astiav.FilterContext),
+		buffersrcContexts: make(map[string]*astiav.FilterContext),
 		c:                 astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		content:           o.Content,
 		eh:                eh,
+		inputCtxs:         make(map[string]Context),
+		inputNames:        make(map[astiencoder.Node][]string),
 		outputCtx:         o.OutputCtx,
 		restamper:         o.Restamper,
 	}
 
+	// Create sync queue
+	if o.SyncInputs && len(o.Inputs) > 1 {
+		f.sq = newFiltererSyncQueue()
+	}
+
 	// Create base node
 	f.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, f, astiencoder.EventTypeToNodeEventName)
 
+	// Create end of stream tracker
+	f.eos = newEOSTracker(f)
+
 	// Create frame pool
 	f.p = newFramePool(f)
 
 	// Create frame dispatcher
-	f.d = newFrameDispatcher(f, eh)
+	f.d = newFrameDispatcherWithOptions(f, eh, o.Dispatcher)
 
 	// Add stat options
 	f.addStatOptions()
@@ -81,14 +107,46 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 		f.emulatePeriod = time.Duration(o.EmulateRate.Den() * 1e9 / o.EmulateRate.Num())
 	}
 
+	// Loop through inputs
+	for n, i := range o.Inputs {
+		// Get context
+		v, ok := i.(OutputContexter)
+		if !ok {
+			err = fmt.Errorf("astilibav: input %s is not an OutputContexter", n)
+			return
+		}
+
+		// Store input
+		f.inputCtxs[n] = v.OutputCtx()
+		f.inputNames[i] = append(f.inputNames[i], n)
+	}
+
+	// Make sure the graph is properly closed
+	f.AddCloseWithError(func() error { return f.gc.Close() })
+
+	// Create graph
+	if err = f.createGraph(); err != nil {
+		err = fmt.Errorf("astilibav: creating graph failed: %w", err)
+		return
+	}
+	return
+}
+
+func (f *Filterer) createGraph() (err error) {
+	// Create graph closer
+	f.gc = astikit.NewCloser()
+
+	// Reset buffersrc contexts
+	f.buffersrcContexts = make(map[string]*astiav.FilterContext)
+
 	// Create graph
 	f.g = astiav.AllocFilterGraph()
-	f.AddClose(f.g.Free)
+	f.gc.Add(f.g.Free)
 
 	// Create buffersrc func and buffersink
 	var buffersrcFunc func() *astiav.Filter
 	var buffersink *astiav.Filter
-	switch o.OutputCtx.MediaType {
+	switch f.outputCtx.MediaType {
 	case astiav.MediaTypeAudio:
 		buffersrcFunc = func() *astiav.Filter { return astiav.FindFilterByName("abuffer") }
 		buffersink = astiav.FindFilterByName("abuffersink")
@@ -96,7 +154,7 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 		buffersrcFunc = func() *astiav.Filter { return astiav.FindFilterByName("buffer") }
 		buffersink = astiav.FindFilterByName("buffersink")
 	default:
-		err = fmt.Errorf("astilibav: media type %s is not handled by filterer", o.OutputCtx.MediaType)
+		err = fmt.Errorf("astilibav: media type %s is not handled by filterer", f.outputCtx.MediaType)
 		return
 	}
 
@@ -113,32 +171,24 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 	}
 
 	// Make sure buffersink context is freed
-	f.AddClose(f.buffersinkContext.Free)
+	f.gc.Add(f.buffersinkContext.Free)
 
 	// Create inputs
 	inputs := astiav.AllocFilterInOut()
-	f.AddClose(inputs.Free)
+	f.gc.Add(inputs.Free)
 	inputs.SetName("out")
 	inputs.SetFilterContext(f.buffersinkContext)
 	inputs.SetPadIdx(0)
 	inputs.SetNext(nil)
 
-	// Loop through options inputs
+	// Loop through inputs
 	var outputs *astiav.FilterInOut
 	defer func() {
 		if outputs != nil {
 			outputs.Free()
 		}
 	}()
-	for n, i := range o.Inputs {
-		// Get context
-		v, ok := i.(OutputContexter)
-		if !ok {
-			err = fmt.Errorf("astilibav: input %s is not an OutputContexter", n)
-			return
-		}
-		ctx := v.OutputCtx()
-
+	for n, ctx := range f.inputCtxs {
 		// Create buffersrc
 		buffersrc := buffersrcFunc()
 
@@ -185,7 +235,7 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 		}
 
 		// Make sure buffersrc context is freed
-		f.AddClose(buffersrcCtx.Free)
+		f.gc.Add(buffersrcCtx.Free)
 
 		// Create outputs
 		o := astiav.AllocFilterInOut()
@@ -195,14 +245,14 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 		o.SetNext(outputs)
 
 		// Store ctx
-		f.buffersrcContexts[i] = append(f.buffersrcContexts[i], buffersrcCtx)
+		f.buffersrcContexts[n] = buffersrcCtx
 
 		// Set outputs
 		outputs = o
 	}
 
 	// Parse filter
-	if err = f.g.Parse(o.Content, inputs, outputs); err != nil {
+	if err = f.g.Parse(f.content, inputs, outputs); err != nil {
 		err = fmt.Errorf("astilibav: parsing filter failed: %w", err)
 		return
 	}
@@ -212,6 +262,11 @@ func NewFilterer(o FiltererOptions, eh *astiencoder.EventHandler, c *astikit.Clo
 		err = fmt.Errorf("astilibav: configuring filter failed: %w", err)
 		return
 	}
+
+	// Frames are pulled out of the buffersink with its negotiated timebase
+	if is := f.buffersinkContext.Inputs(); len(is) > 0 {
+		f.outputCtx.TimeBase = is[0].TimeBase()
+	}
 	return
 }
 
@@ -290,7 +345,7 @@ func (f *Filterer) Disconnect(h FrameHandler) {
 func (f *Filterer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 	f.BaseNode.Start(ctx, t, func(t *astikit.Task) {
 		// In case there are no inputs, we emulate frames coming in
-		if len(f.buffersrcContexts) == 0 {
+		if len(f.inputCtxs) == 0 {
 			nextAt := time.Now()
 			desc := newFiltererDescriptor(f.buffersinkContext, nil)
 			for {
@@ -306,6 +361,11 @@ func (f *Filterer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 
 		// Start queue
 		f.c.Start(f.Context())
+
+		// Make sure frames remaining in the sync queue are fed to the graph
+		if f.sq != nil {
+			f.feedSyncQueue(true)
+		}
 	})
 }
 
@@ -329,8 +389,76 @@ func (f *Filterer) tickFunc(nextAt *time.Time, desc Descriptor) (stop bool) {
 	return
 }
 
+// HandleEOS implements the EOSHandler interface
+func (f *Filterer) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !f.eos.add(p.Node) {
+					return
+				}
+
+				// Flush
+				f.flushGraph()
+
+				// Dispatch end of stream
+				f.d.dispatchEOS()
+			})
+		})
+	})
+}
+
 // HandleFrame implements the FrameHandler interface
 func (f *Filterer) HandleFrame(p FrameHandlerPayload) {
+	f.handleFrame(p, f.inputNames[p.Node])
+}
+
+// FiltererInput is a FrameHandler feeding a specific input of the filterer
+type FiltererInput struct {
+	*Filterer
+	name string
+}
+
+// Input returns the FrameHandler feeding the input with the provided name
+func (f *Filterer) Input(name string) (i *FiltererInput, err error) {
+	// Input doesn't exist
+	if _, ok := f.inputCtxs[name]; !ok {
+		err = fmt.Errorf("astilibav: input %s doesn't exist", name)
+		return
+	}
+
+	// Create input
+	i = &FiltererInput{
+		Filterer: f,
+		name:     name,
+	}
+	return
+}
+
+// ConnectInput connects the source to the input with the provided name
+func (f *Filterer) ConnectInput(name string, src FrameHandlerConnector) (err error) {
+	// Get input
+	var i *FiltererInput
+	if i, err = f.Input(name); err != nil {
+		err = fmt.Errorf("astilibav: getting input failed: %w", err)
+		return
+	}
+
+	// Connect
+	src.Connect(i)
+	return
+}
+
+// HandleFrame implements the FrameHandler interface
+func (i *FiltererInput) HandleFrame(p FrameHandlerPayload) {
+	i.handleFrame(p, []string{i.name})
+}
+
+func (f *Filterer) handleFrame(p FrameHandlerPayload, names []string) {
 	// Everything executed outside the main loop should be protected from the closer
 	f.DoWhenUnclosed(func() {
 		// Increment received frames
@@ -356,33 +484,245 @@ func (f *Filterer) HandleFrame(p FrameHandlerPayload) {
 				// Increment processed frames
 				atomic.AddUint64(&f.statFramesProcessed, 1)
 
-				// Retrieve buffer ctxs
-				buffersrcContexts, ok := f.buffersrcContexts[p.Node]
-				if !ok {
+				// No inputs
+				if len(names) == 0 {
 					return
 				}
 
-				// Loop through buffer ctxs
-				for _, buffersrcContext := range buffersrcContexts {
-					// Add frame
-					if err := buffersrcContext.BuffersrcAddFrame(fm, astiav.NewBuffersrcFlags(astiav.BuffersrcFlagKeepRef)); err != nil {
-						emitError(f, f.eh, err, "adding frame to buffersrc")
-						return
+				// Inputs are synchronized
+				if f.sq != nil {
+					// Add frame to sync queue
+					for _, n := range names {
+						if err := f.sq.add(f.p, fm, n, p.Descriptor); err != nil {
+							emitError(f, f.eh, err, "adding frame to sync queue")
+							return
+						}
 					}
+
+					// Feed sync queue
+					f.feedSyncQueue(false)
+					return
 				}
 
-				// Loop
-				for {
-					// Pull filtered frame
-					if stop := f.pullFilteredFrame(p.Descriptor); stop {
+				// Loop through inputs
+				for _, n := range names {
+					// Add frame
+					if err := f.addFrame(fm, n); err != nil {
+						emitError(f, f.eh, err, "adding frame")
 						return
 					}
 				}
+
+				// Pull filtered frames
+				f.pullFilteredFrames(p.Descriptor)
 			})
 		})
 	})
 }
 
+// flushGraph feeds remaining frames and end of stream to the graph and pulls remaining filtered
+// frames
+func (f *Filterer) flushGraph() {
+	// Feed frames remaining in the sync queue
+	if f.sq != nil {
+		f.feedSyncQueue(true)
+	}
+
+	// Loop through buffersrc contexts
+	for n, c := range f.buffersrcContexts {
+		// Add end of stream
+		if err := c.BuffersrcAddFrame(nil, astiav.NewBuffersrcFlags()); err != nil {
+			emitError(f, f.eh, err, "adding end of stream to buffersrc %s", n)
+		}
+	}
+
+	// Pull filtered frames
+	f.pullFilteredFrames(f.outputCtx.Descriptor())
+}
+
+func (f *Filterer) pullFilteredFrames(descriptor Descriptor) {
+	for {
+		// Pull filtered frame
+		if stop := f.pullFilteredFrame(descriptor); stop {
+			return
+		}
+	}
+}
+
+func (f *Filterer) feedSyncQueue(all bool) {
+	for {
+		// Get next frame
+		i := f.sq.next(f.inputCtxs, all)
+		if i == nil {
+			return
+		}
+
+		// Add frame
+		err := f.addFrame(i.f, i.name)
+
+		// Make sure to close frame
+		f.p.put(i.f)
+
+		// Process error
+		if err != nil {
+			emitError(f, f.eh, err, "adding frame")
+			continue
+		}
+
+		// Pull filtered frames
+		f.pullFilteredFrames(i.descriptor)
+	}
+}
+
+func (f *Filterer) addFrame(fm *astiav.Frame, name string) (err error) {
+	// Input format has changed
+	if ctx, ok := f.inputCtxs[name]; ok && filtererInputCtxHasChanged(ctx, fm) {
+		// Reconfigure
+		if err = f.reconfigure(name, fm); err != nil {
+			err = fmt.Errorf("astilibav: reconfiguring failed: %w", err)
+			return
+		}
+	}
+
+	// Get buffersrc context
+	buffersrcContext, ok := f.buffersrcContexts[name]
+	if !ok {
+		return
+	}
+
+	// Add frame
+	if err = buffersrcContext.BuffersrcAddFrame(fm, astiav.NewBuffersrcFlags(astiav.BuffersrcFlagKeepRef)); err != nil {
+		err = fmt.Errorf("astilibav: adding frame to buffersrc failed: %w", err)
+		return
+	}
+	return
+}
+
+func filtererInputCtxHasChanged(ctx Context, f *astiav.Frame) bool {
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		return f.SampleFormat() != ctx.SampleFormat || f.SampleRate() != ctx.SampleRate ||
+			(f.ChannelLayout() > 0 && f.ChannelLayout() != ctx.ChannelLayout)
+	case astiav.MediaTypeVideo:
+		return f.Width() != ctx.Width || f.Height() != ctx.Height || f.PixelFormat() != ctx.PixelFormat
+	}
+	return false
+}
+
+// FiltererReconfiguration is the payload of EventNameFiltererReconfigured
+type FiltererReconfiguration struct {
+	Input string
+	New   Context
+	Old   Context
+}
+
+func (f *Filterer) reconfigure(name string, fm *astiav.Frame) (err error) {
+	// Update input ctx
+	old := f.inputCtxs[name]
+	ctx := old
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		ctx.SampleFormat = fm.SampleFormat()
+		ctx.SampleRate = fm.SampleRate()
+		if fm.ChannelLayout() > 0 {
+			ctx.ChannelLayout = fm.ChannelLayout()
+			ctx.Channels = fm.ChannelLayout().NbChannels()
+		}
+	case astiav.MediaTypeVideo:
+		ctx.Height = fm.Height()
+		ctx.PixelFormat = fm.PixelFormat()
+		ctx.Width = fm.Width()
+	}
+	f.inputCtxs[name] = ctx
+
+	// Close previous graph
+	if err = f.gc.Close(); err != nil {
+		err = fmt.Errorf("astilibav: closing graph failed: %w", err)
+		return
+	}
+
+	// Create graph
+	if err = f.createGraph(); err != nil {
+		err = fmt.Errorf("astilibav: creating graph failed: %w", err)
+		return
+	}
+
+	// Emit event
+	f.eh.Emit(astiencoder.Event{
+		Name: EventNameFiltererReconfigured,
+		Payload: FiltererReconfiguration{
+			Input: name,
+			New:   ctx,
+			Old:   old,
+		},
+		Target: f,
+	})
+	return
+}
+
+type filtererSyncQueue struct {
+	// Indexed by input name
+	items map[string][]*filtererSyncQueueItem
+}
+
+type filtererSyncQueueItem struct {
+	descriptor Descriptor
+	f          *astiav.Frame
+	name       string
+	// In nanoseconds
+	pts int64
+}
+
+func newFiltererSyncQueue() *filtererSyncQueue {
+	return &filtererSyncQueue{items: make(map[string][]*filtererSyncQueueItem)}
+}
+
+func (q *filtererSyncQueue) add(p *framePool, src *astiav.Frame, name string, descriptor Descriptor) error {
+	// Copy frame
+	f := p.get()
+	if err := f.Ref(src); err != nil {
+		p.put(f)
+		return fmt.Errorf("astilibav: refing frame failed: %w", err)
+	}
+
+	// Append item
+	q.items[name] = append(q.items[name], &filtererSyncQueueItem{
+		descriptor: descriptor,
+		f:          f,
+		name:       name,
+		pts:        astiav.RescaleQ(f.Pts(), descriptor.TimeBase(), nanosecondRational),
+	})
+	return nil
+}
+
+// Returns the item with the smallest pts once every input has at least one item, or as soon as
+// there's an item if all is true
+func (q *filtererSyncQueue) next(inputCtxs map[string]Context, all bool) (i *filtererSyncQueueItem) {
+	// Loop through inputs
+	for n := range inputCtxs {
+		// Get items
+		is := q.items[n]
+		if len(is) == 0 {
+			// We need to wait for an item for this input
+			if !all {
+				return nil
+			}
+			continue
+		}
+
+		// Update item
+		if i == nil || is[0].pts < i.pts {
+			i = is[0]
+		}
+	}
+
+	// Remove item
+	if i != nil {
+		q.items[i.name] = q.items[i.name][1:]
+	}
+	return
+}
+
 func (f *Filterer) pullFilteredFrame(descriptor Descriptor) (stop bool) {
 	// Get frame
 	fm := f.p.get()
@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/asticode/go-astiav"
 	"github.com/asticode/go-astiencoder"
 	"github.com/asticode/go-astikit"
 )
@@ -18,18 +19,35 @@ type Forwarder struct {
 	c                   *astikit.Chan
 	d                   *frameDispatcher
 	eh                  *astiencoder.EventHandler
+	eos                 *eosTracker
+	onFrame             func(f *astiav.Frame) error
 	outputCtx           Context
 	p                   *framePool
+	predicate           func(p FrameHandlerPayload) bool
 	restamper           FrameRestamper
+	statFramesDropped   uint64
 	statFramesProcessed uint64
 	statFramesReceived  uint64
 }
 
 // ForwarderOptions represents forwarder options
 type ForwarderOptions struct {
-	Node      astiencoder.NodeOptions
+	// Dispatcher options, used to choose how frames are dispatched to several handlers
+	Dispatcher FrameDispatcherOptions
+	// If > 0, caps the number of frames buffered by the forwarder: once reached, HandleFrame blocks
+	// until a buffered frame has been processed, applying backpressure to the parent
+	MaxBufferedFrames int
+	Node              astiencoder.NodeOptions
+	// Executed in the forwarder's loop before the frame is restamped and dispatched.
+	// If an error is returned, the frame is not dispatched
+	OnFrame   func(f *astiav.Frame) error
 	OutputCtx Context
-	Restamper FrameRestamper
+	// Executed in the forwarder's loop. If false is returned, the frame is dropped
+	Predicate func(p FrameHandlerPayload) bool
+	// Number of frames allocated when the forwarder is created, so that latency-sensitive pipelines
+	// don't pay the allocation cost on the first frames
+	PrewarmedFrames int
+	Restamper       FrameRestamper
 }
 
 // NewForwarder creates a new forwarder
@@ -42,18 +60,24 @@ func NewForwarder(o ForwarderOptions, eh *astiencoder.EventHandler, c *astikit.C
 	f = &Forwarder{
 		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
 		eh:        eh,
+		onFrame:   o.OnFrame,
 		outputCtx: o.OutputCtx,
+		predicate: o.Predicate,
 		restamper: o.Restamper,
 	}
 
 	// Create base node
 	f.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, f, astiencoder.EventTypeToNodeEventName)
 
+	// Create end of stream tracker
+	f.eos = newEOSTracker(f)
+
 	// Create frame pool
-	f.p = newFramePool(f)
+	f.p = newBoundedFramePool(f, o.MaxBufferedFrames)
+	f.p.prewarm(o.PrewarmedFrames)
 
 	// Create frame dispatcher
-	f.d = newFrameDispatcher(f, eh)
+	f.d = newFrameDispatcherWithOptions(f, eh, o.Dispatcher)
 
 	// Add stat options
 	f.addStatOptions()
@@ -63,6 +87,7 @@ func NewForwarder(o ForwarderOptions, eh *astiencoder.EventHandler, c *astikit.C
 type ForwarderStats struct {
 	FramesAllocated uint64
 	FramesDispached uint64
+	FramesDropped   uint64
 	FramesProcessed uint64
 	FramesReceived  uint64
 	WorkDuration    time.Duration
@@ -72,6 +97,7 @@ func (f *Forwarder) Stats() ForwarderStats {
 	return ForwarderStats{
 		FramesAllocated: f.p.stats().framesAllocated,
 		FramesDispached: f.d.stats().framesDispatched,
+		FramesDropped:   atomic.LoadUint64(&f.statFramesDropped),
 		FramesProcessed: atomic.LoadUint64(&f.statFramesProcessed),
 		FramesReceived:  atomic.LoadUint64(&f.statFramesReceived),
 		WorkDuration:    f.c.Stats().WorkDuration,
@@ -102,6 +128,15 @@ func (f *Forwarder) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicUint64RateStat(&f.statFramesProcessed),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames dropped per second",
+				Label:       "Dropped rate",
+				Name:        StatNameDroppedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&f.statFramesDropped),
+		},
 	)
 
 	// Add stats
@@ -122,6 +157,16 @@ func (f *Forwarder) Connect(h FrameHandler) {
 	astiencoder.ConnectNodes(f, h)
 }
 
+// ConnectWithRestamper connects the handler which receives its own copy of frames restamped
+// with the provided restamper. It's applied after the forwarder's restamper, if any
+func (f *Forwarder) ConnectWithRestamper(h FrameHandler, r FrameRestamper) {
+	// Add handler
+	f.d.addHandlerWithRestamper(h, r)
+
+	// Connect nodes
+	astiencoder.ConnectNodes(f, h)
+}
+
 // Disconnect implements the FrameHandlerConnector interface
 func (f *Forwarder) Disconnect(h FrameHandler) {
 	// Delete handler
@@ -142,6 +187,26 @@ func (f *Forwarder) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 	})
 }
 
+// HandleEOS implements the EOSHandler interface
+func (f *Forwarder) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !f.eos.add(p.Node) {
+					return
+				}
+
+				// Dispatch end of stream
+				f.d.dispatchEOS()
+			})
+		})
+	})
+}
+
 // HandleFrame implements the FrameHandler interface
 func (f *Forwarder) HandleFrame(p FrameHandlerPayload) {
 	// Everything executed outside the main loop should be protected from the closer
@@ -150,8 +215,10 @@ func (f *Forwarder) HandleFrame(p FrameHandlerPayload) {
 		atomic.AddUint64(&f.statFramesReceived, 1)
 
 		// Copy frame
+		// Side data is preserved
 		fm := f.p.get()
 		if err := fm.Ref(p.Frame); err != nil {
+			f.p.put(fm)
 			emitError(f, f.eh, err, "refing frame")
 			return
 		}
@@ -169,6 +236,24 @@ func (f *Forwarder) HandleFrame(p FrameHandlerPayload) {
 				// Increment processed frames
 				atomic.AddUint64(&f.statFramesProcessed, 1)
 
+				// Frame should be dropped
+				if f.predicate != nil && !f.predicate(FrameHandlerPayload{
+					Descriptor: p.Descriptor,
+					Frame:      fm,
+					Node:       p.Node,
+				}) {
+					atomic.AddUint64(&f.statFramesDropped, 1)
+					return
+				}
+
+				// Custom callback
+				if f.onFrame != nil {
+					if err := f.onFrame(fm); err != nil {
+						emitError(f, f.eh, err, "executing frame callback")
+						return
+					}
+				}
+
 				// Restamp
 				if f.restamper != nil {
 					f.restamper.Restamp(fm)
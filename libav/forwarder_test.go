@@ -0,0 +1,77 @@
+package astilibav
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/require"
+)
+
+type mockedFrameHandler struct {
+	*astiencoder.BaseNode
+	fn func(p FrameHandlerPayload)
+}
+
+func newMockedFrameHandler(c *astikit.Closer, eh *astiencoder.EventHandler, fn func(p FrameHandlerPayload)) *mockedFrameHandler {
+	return newNamedMockedFrameHandler("mocked", c, eh, fn)
+}
+
+func newNamedMockedFrameHandler(name string, c *astikit.Closer, eh *astiencoder.EventHandler, fn func(p FrameHandlerPayload)) (h *mockedFrameHandler) {
+	h = &mockedFrameHandler{fn: fn}
+	h.BaseNode = astiencoder.NewBaseNode(astiencoder.NodeOptions{Metadata: astiencoder.NodeMetadata{Name: name}}, c, eh, nil, h, astiencoder.EventTypeToNodeEventName)
+	return
+}
+
+func (h *mockedFrameHandler) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {}
+
+func (h *mockedFrameHandler) HandleFrame(p FrameHandlerPayload) { h.fn(p) }
+
+func TestForwarderSideData(t *testing.T) {
+	// Create frame with side data
+	f := astiav.AllocFrame()
+	require.NotNil(t, f)
+	defer f.Free()
+	sd := f.NewSideData(astiav.FrameSideDataTypeA53Cc, 3)
+	require.NotNil(t, sd)
+	sd.SetData([]byte("abc"))
+
+	// Create forwarder
+	eh := astiencoder.NewEventHandler()
+	c := astikit.NewCloser()
+	defer c.Close()
+	fw := NewForwarder(ForwarderOptions{}, eh, c, nil)
+
+	// Connect handler
+	ch := make(chan []byte, 1)
+	fw.Connect(newMockedFrameHandler(c, eh, func(p FrameHandlerPayload) {
+		var b []byte
+		if sd := p.Frame.SideData(astiav.FrameSideDataTypeA53Cc); sd != nil {
+			b = append(b, sd.Data()...)
+		}
+		ch <- b
+	}))
+
+	// Start forwarder
+	w := astikit.NewWorker(astikit.WorkerOptions{})
+	defer w.Stop()
+	fw.Start(w.Context(), w.NewTask)
+	defer fw.Stop()
+
+	// Handle frame
+	fw.HandleFrame(FrameHandlerPayload{
+		Descriptor: NewDescriptor(astiav.NewRational(1, 1)),
+		Frame:      f,
+	})
+
+	// Side data should have survived the copy
+	select {
+	case b := <-ch:
+		require.Equal(t, []byte("abc"), b)
+	case <-time.After(time.Second):
+		t.Fatal("frame was not dispatched")
+	}
+}
@@ -1,6 +1,8 @@
 package astilibav
 
 import (
+	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,7 +12,9 @@ import (
 	"github.com/asticode/go-astikit"
 )
 
-// FrameHandler represents a node that can handle a frame
+// FrameHandler represents a node that can handle a frame.
+// Nodes copying incoming frames use Ref which copies frame properties as well, therefore side data
+// (e.g. A53 captions or HDR metadata) survives the copy
 type FrameHandler interface {
 	astiencoder.Node
 	HandleFrame(p FrameHandlerPayload)
@@ -29,63 +33,193 @@ type FrameHandlerPayload struct {
 	Node       astiencoder.Node
 }
 
+// FrameDispatchMode represents the way frames are dispatched to several handlers
+type FrameDispatchMode string
+
+// Frame dispatch modes
+const (
+	// Handlers are called one after the other, sorted by node name
+	FrameDispatchModeSequential FrameDispatchMode = "sequential"
+	// Each handler is called in its own goroutine and dispatching returns once they're all done,
+	// so that a slow handler doesn't delay the other ones
+	FrameDispatchModeConcurrent FrameDispatchMode = "concurrent"
+)
+
+// FrameDispatcherOptions represents frame dispatcher options
+type FrameDispatcherOptions struct {
+	// Defaults to FrameDispatchModeSequential
+	Mode FrameDispatchMode
+}
+
+// Handlers can be added or deleted while the node is dispatching: each mutation rebuilds an immutable
+// snapshot of targets that dispatching reads without locking (copy-on-write). A handler deleted while
+// a frame is being dispatched may still receive that frame
 type frameDispatcher struct {
 	eh                   *astiencoder.EventHandler
 	hs                   map[string]FrameHandler
-	m                    *sync.Mutex // Locks hs
+	m                    *sync.Mutex // Locks hs, rs and ts updates
 	n                    astiencoder.Node
+	o                    FrameDispatcherOptions
+	p                    *framePool
+	rs                   map[string]FrameRestamper
 	statFramesDispatched uint64
+	ts                   atomic.Value // Stores []frameDispatcherTarget
+}
+
+type frameDispatcherTarget struct {
+	h FrameHandler
+	r FrameRestamper
 }
 
 func newFrameDispatcher(n astiencoder.Node, eh *astiencoder.EventHandler) *frameDispatcher {
-	return &frameDispatcher{
+	return newFrameDispatcherWithOptions(n, eh, FrameDispatcherOptions{})
+}
+
+func newFrameDispatcherWithOptions(n astiencoder.Node, eh *astiencoder.EventHandler, o FrameDispatcherOptions) *frameDispatcher {
+	// Default options
+	if o.Mode == "" {
+		o.Mode = FrameDispatchModeSequential
+	}
+
+	// Create dispatcher
+	d := &frameDispatcher{
 		eh: eh,
 		hs: make(map[string]FrameHandler),
 		m:  &sync.Mutex{},
 		n:  n,
+		o:  o,
+		p:  newFramePool(n),
+		rs: make(map[string]FrameRestamper),
 	}
+
+	// Store targets
+	d.ts.Store([]frameDispatcherTarget{})
+	return d
 }
 
 func (d *frameDispatcher) addHandler(h FrameHandler) {
 	d.m.Lock()
 	defer d.m.Unlock()
 	d.hs[h.Metadata().Name] = h
+	d.updateTargets()
+}
+
+// Handler receives its own copy of the frame restamped with the provided restamper
+func (d *frameDispatcher) addHandlerWithRestamper(h FrameHandler, r FrameRestamper) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	d.hs[h.Metadata().Name] = h
+	d.rs[h.Metadata().Name] = r
+	d.updateTargets()
 }
 
 func (d *frameDispatcher) delHandler(h FrameHandler) {
 	d.m.Lock()
 	defer d.m.Unlock()
 	delete(d.hs, h.Metadata().Name)
+	delete(d.rs, h.Metadata().Name)
+	d.updateTargets()
+}
+
+// Assumes the mutex is locked
+func (d *frameDispatcher) updateTargets() {
+	// Create targets
+	ts := make([]frameDispatcherTarget, 0, len(d.hs))
+	for n, h := range d.hs {
+		ts = append(ts, frameDispatcherTarget{
+			h: h,
+			r: d.rs[n],
+		})
+	}
+
+	// Sort targets
+	sort.Slice(ts, func(i, j int) bool { return ts[i].h.Metadata().Name < ts[j].h.Metadata().Name })
+
+	// Store targets
+	// A new slice is stored so that dispatching never sees it change
+	d.ts.Store(ts)
 }
 
 func (d *frameDispatcher) dispatch(f *astiav.Frame, descriptor Descriptor) {
 	// Increment dispatched frames
 	atomic.AddUint64(&d.statFramesDispatched, 1)
 
-	// Get handlers
-	d.m.Lock()
-	var hs []FrameHandler
-	for _, h := range d.hs {
-		hs = append(hs, h)
+	// Describe frame media parameters
+	descriptor = newFrameMediaDescriptor(f, descriptor)
+
+	// Get targets
+	ts := d.ts.Load().([]frameDispatcherTarget)
+
+	// No targets
+	if len(ts) == 0 {
+		return
 	}
-	d.m.Unlock()
 
-	// No handlers
-	if len(hs) == 0 {
+	// Dispatch concurrently
+	if d.o.Mode == FrameDispatchModeConcurrent && len(ts) > 1 {
+		wg := &sync.WaitGroup{}
+		for _, t := range ts {
+			wg.Add(1)
+			go func(t frameDispatcherTarget) {
+				defer wg.Done()
+				d.dispatchToTarget(t, f, descriptor)
+			}(t)
+		}
+		wg.Wait()
 		return
 	}
 
-	// Loop through handlers
-	for _, h := range hs {
-		// Handle frame
-		h.HandleFrame(FrameHandlerPayload{
-			Descriptor: descriptor,
-			Frame:      f,
-			Node:       d.n,
-		})
+	// Loop through targets
+	// They are already sorted by node name
+	for _, t := range ts {
+		d.dispatchToTarget(t, f, descriptor)
 	}
 }
 
+// dispatchEOS lets handlers know the node has reached end of stream
+func (d *frameDispatcher) dispatchEOS() {
+	for _, t := range d.ts.Load().([]frameDispatcherTarget) {
+		if v, ok := t.h.(EOSHandler); ok {
+			v.HandleEOS(EOSHandlerPayload{Node: d.n})
+		}
+	}
+}
+
+func (d *frameDispatcher) dispatchToTarget(t frameDispatcherTarget, f *astiav.Frame, descriptor Descriptor) {
+	// Handler has its own restamper
+	if t.r != nil {
+		d.dispatchRestamped(t.h, t.r, f, descriptor)
+		return
+	}
+
+	// Handle frame
+	t.h.HandleFrame(FrameHandlerPayload{
+		Descriptor: descriptor,
+		Frame:      f,
+		Node:       d.n,
+	})
+}
+
+func (d *frameDispatcher) dispatchRestamped(h FrameHandler, r FrameRestamper, f *astiav.Frame, descriptor Descriptor) {
+	// Copy frame
+	fm := d.p.get()
+	defer d.p.put(fm)
+	if err := fm.Ref(f); err != nil {
+		emitError(d.n, d.eh, err, "refing frame")
+		return
+	}
+
+	// Restamp
+	r.Restamp(fm)
+
+	// Handle frame
+	h.HandleFrame(FrameHandlerPayload{
+		Descriptor: descriptor,
+		Frame:      fm,
+		Node:       d.n,
+	})
+}
+
 type frameDispatcherStats struct {
 	framesDispatched uint64
 }
@@ -108,23 +242,38 @@ func (d *frameDispatcher) statOptions() []astikit.StatOptions {
 	}
 }
 
+// Frames are only allocated when the pool is empty, therefore the number of allocated frames is the
+// maximum number of frames used at once
 type framePool struct {
 	c                   astiencoder.Closer
 	m                   *sync.Mutex
 	p                   []*astiav.Frame
+	sem                 chan struct{}
 	statFramesAllocated uint64
+	statFramesUsed      int64
 }
 
 func newFramePool(c astiencoder.Closer) *framePool {
-	return &framePool{
+	return newBoundedFramePool(c, 0)
+}
+
+// If max > 0, get() blocks once max frames are used until one is put back or the node is stopped
+func newBoundedFramePool(c astiencoder.Closer, max int) (p *framePool) {
+	p = &framePool{
 		c: c,
 		m: &sync.Mutex{},
 	}
+	if max > 0 {
+		p.sem = make(chan struct{}, max)
+	}
+	return
 }
 
 func (p *framePool) get() (f *astiav.Frame) {
+	poolAcquire(p.c, p.sem)
 	p.m.Lock()
 	defer p.m.Unlock()
+	atomic.AddInt64(&p.statFramesUsed, 1)
 	if len(p.p) == 0 {
 		f = astiav.AllocFrame()
 		atomic.AddUint64(&p.statFramesAllocated, 1)
@@ -136,19 +285,70 @@ func (p *framePool) get() (f *astiav.Frame) {
 	return
 }
 
+// prewarm allocates n frames upfront so that the first get() calls don't pay the allocation cost
+func (p *framePool) prewarm(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for i := 0; i < n; i++ {
+		f := astiav.AllocFrame()
+		atomic.AddUint64(&p.statFramesAllocated, 1)
+		p.c.AddClose(f.Free)
+		p.p = append(p.p, f)
+	}
+}
+
 func (p *framePool) put(f *astiav.Frame) {
 	p.m.Lock()
 	defer p.m.Unlock()
 	f.Unref()
 	p.p = append(p.p, f)
+	atomic.AddInt64(&p.statFramesUsed, -1)
+	poolRelease(p.sem)
+}
+
+func poolAcquire(c astiencoder.Closer, sem chan struct{}) {
+	// Pool is not bounded
+	if sem == nil {
+		return
+	}
+
+	// Get node context
+	// Nodes that haven't been started yet don't have a context
+	var done <-chan struct{}
+	if v, ok := c.(interface{ Context() context.Context }); ok && v.Context() != nil {
+		done = v.Context().Done()
+	}
+
+	// Wait for a slot, unless the node is stopped in which case the pool is not bounded anymore
+	select {
+	case sem <- struct{}{}:
+	case <-done:
+	}
+}
+
+func poolRelease(sem chan struct{}) {
+	// Pool is not bounded
+	if sem == nil {
+		return
+	}
+
+	// Release slot if any was acquired
+	select {
+	case <-sem:
+	default:
+	}
 }
 
 type framePoolStats struct {
 	framesAllocated uint64
+	framesUsed      int64
 }
 
 func (p *framePool) stats() framePoolStats {
-	return framePoolStats{framesAllocated: atomic.LoadUint64(&p.statFramesAllocated)}
+	return framePoolStats{
+		framesAllocated: atomic.LoadUint64(&p.statFramesAllocated),
+		framesUsed:      atomic.LoadInt64(&p.statFramesUsed),
+	}
 }
 
 func (p *framePool) statOptions() []astikit.StatOptions {
@@ -162,5 +362,14 @@ func (p *framePool) statOptions() []astikit.StatOptions {
 			},
 			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return atomic.LoadUint64(&p.statFramesAllocated) }),
 		},
+		{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames taken from the pool and not put back yet",
+				Label:       "Used frames",
+				Name:        StatNameUsedFrames,
+				Unit:        "f",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return atomic.LoadInt64(&p.statFramesUsed) }),
+		},
 	}
 }
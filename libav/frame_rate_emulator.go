@@ -15,19 +15,34 @@ import (
 var countFrameRateEmulator uint64
 
 type frameRateEmulatorPTSReference struct {
-	pts  int64
-	time time.Time
+	pts   int64
+	speed float64
+	time  time.Time
+}
+
+func newFrameRateEmulatorPTSReference(r PTSReference, timeBase astiav.Rational, speed float64) frameRateEmulatorPTSReference {
+	return frameRateEmulatorPTSReference{
+		pts:   astiav.RescaleQ(r.PTS, r.TimeBase, timeBase),
+		speed: speed,
+		time:  r.Time,
+	}
 }
 
 type FrameRateEmulator struct {
 	*astiencoder.BaseNode
 	c                   *astikit.Chan
+	clock               Clock
 	d                   *frameDispatcher
+	dropLate            bool
 	eh                  *astiencoder.EventHandler
+	lastPTS             int64
 	outputCtx           Context
 	p                   *framePool
 	ptsReference        frameRateEmulatorPTSReference
+	period              time.Duration
 	r                   *rateEmulator
+	reorderWindow       int
+	statFramesDropped   uint64
 	statFramesProcessed uint64
 	statFramesReceived  uint64
 }
@@ -39,10 +54,27 @@ type PTSReference struct {
 }
 
 type FrameRateEmulatorOptions struct {
+	// Defaults to SystemClock. To keep several emulators in sync, provide the same SharedClock and
+	// PTS references created with SharedClock.PTSReference
+	Clock Clock
+	// If true, frames whose scheduled time is more than one frame period in the past are dropped
+	// instead of being dispatched. OutputCtx.FrameRate is mandatory in that case
+	DropLate     bool
 	FlushOnStop  bool
 	Node         astiencoder.NodeOptions
 	OutputCtx    Context
 	PTSReference PTSReference
+	// Frames are dispatched in PTS order as long as they're received before the previous frame has
+	// been dispatched. If frames are received in decode order (e.g. with B-frames) close to their
+	// scheduled time, they may be dispatched out of order.
+	// If > 0, it's the maximum number of positions by which frames can be reordered: the first frame is
+	// dispatched only once more than ReorderWindow frames have been received, which guarantees strict
+	// PTS order at the cost of holding the last ReorderWindow frames until more frames are received or
+	// the node is flushed. Frames received with a PTS older than the last dispatched PTS are dropped.
+	ReorderWindow int
+	// Speed multiplier: 2 means frames are dispatched twice as fast as real time.
+	// Defaults to 1
+	Speed float64
 }
 
 func NewFrameRateEmulator(o FrameRateEmulatorOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (r *FrameRateEmulator) {
@@ -52,13 +84,19 @@ func NewFrameRateEmulator(o FrameRateEmulatorOptions, eh *astiencoder.EventHandl
 
 	// Create frame rate emulator
 	r = &FrameRateEmulator{
-		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
-		eh:        eh,
-		outputCtx: o.OutputCtx,
-		ptsReference: frameRateEmulatorPTSReference{
-			pts:  astiav.RescaleQ(o.PTSReference.PTS, o.PTSReference.TimeBase, o.OutputCtx.TimeBase),
-			time: o.PTSReference.Time,
-		},
+		c:             astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		clock:         clockOrDefault(o.Clock),
+		dropLate:      o.DropLate,
+		eh:            eh,
+		lastPTS:       astiav.NoPtsValue,
+		outputCtx:     o.OutputCtx,
+		ptsReference:  newFrameRateEmulatorPTSReference(o.PTSReference, o.OutputCtx.TimeBase, frameRateEmulatorSpeed(o.Speed)),
+		reorderWindow: o.ReorderWindow,
+	}
+
+	// Get period
+	if o.OutputCtx.FrameRate.ToDouble() > 0 {
+		r.period = time.Duration(float64(1e9) / o.OutputCtx.FrameRate.ToDouble())
 	}
 
 	// Create base node
@@ -71,7 +109,8 @@ func NewFrameRateEmulator(o FrameRateEmulatorOptions, eh *astiencoder.EventHandl
 	r.d = newFrameDispatcher(r, eh)
 
 	// Create rate emulator
-	r.r = newRateEmulator(o.FlushOnStop, r.rateEmulatorAt, r.rateEmulatorBefore, r.rateEmulatorExec)
+	r.r = newRateEmulator(r.clock, o.FlushOnStop, r.rateEmulatorAt, r.rateEmulatorBefore, r.rateEmulatorExec)
+	r.r.setWindow(r.reorderWindow)
 
 	// Add stat options
 	r.addStatOptions()
@@ -81,6 +120,7 @@ func NewFrameRateEmulator(o FrameRateEmulatorOptions, eh *astiencoder.EventHandl
 type FrameRateEmulatorStats struct {
 	FramesAllocated uint64
 	FramesDispached uint64
+	FramesDropped   uint64
 	FramesProcessed uint64
 	FramesReceived  uint64
 	WorkDuration    time.Duration
@@ -90,6 +130,7 @@ func (r *FrameRateEmulator) Stats() FrameRateEmulatorStats {
 	return FrameRateEmulatorStats{
 		FramesAllocated: r.p.stats().framesAllocated,
 		FramesDispached: r.d.stats().framesDispatched,
+		FramesDropped:   atomic.LoadUint64(&r.statFramesDropped),
 		FramesProcessed: atomic.LoadUint64(&r.statFramesProcessed),
 		FramesReceived:  atomic.LoadUint64(&r.statFramesReceived),
 		WorkDuration:    r.c.Stats().WorkDuration,
@@ -120,6 +161,15 @@ func (r *FrameRateEmulator) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicUint64RateStat(&r.statFramesProcessed),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames dropped per second",
+				Label:       "Dropped rate",
+				Name:        StatNameDroppedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&r.statFramesDropped),
+		},
 	)
 
 	// Add stats
@@ -130,6 +180,38 @@ func (r *FrameRateEmulator) SetFlushOnStop(flushOnStop bool) {
 	r.r.setFlushOnStop(flushOnStop)
 }
 
+// SetPTSReference updates the pts reference used to compute when frames should be dispatched.
+// It's useful to re-sync emulated playback after a seek
+func (r *FrameRateEmulator) SetPTSReference(ref PTSReference) {
+	r.r.update(func() {
+		r.ptsReference = newFrameRateEmulatorPTSReference(ref, r.outputCtx.TimeBase, r.ptsReference.speed)
+
+		// PTS may go backward after a seek
+		atomic.StoreInt64(&r.lastPTS, astiav.NoPtsValue)
+	})
+}
+
+// SetSpeed sets the speed multiplier. The pts reference is rebased so that playback
+// remains monotonic
+func (r *FrameRateEmulator) SetSpeed(speed float64) {
+	r.r.update(func() {
+		// Rebase pts reference so that the position at which the speed changes remains the same
+		n := r.clock.Now()
+		r.ptsReference.pts += astiav.RescaleQ(int64(float64(n.Sub(r.ptsReference.time))*r.ptsReference.speed), nanosecondRational, r.outputCtx.TimeBase)
+		r.ptsReference.time = n
+
+		// Update speed
+		r.ptsReference.speed = frameRateEmulatorSpeed(speed)
+	})
+}
+
+func frameRateEmulatorSpeed(speed float64) float64 {
+	if speed <= 0 {
+		return 1
+	}
+	return speed
+}
+
 // OutputCtx returns the output ctx
 func (r *FrameRateEmulator) OutputCtx() Context {
 	return r.outputCtx
@@ -223,17 +305,43 @@ func (r *FrameRateEmulator) HandleFrame(p FrameHandlerPayload) {
 }
 
 func (r *FrameRateEmulator) rateEmulatorAt(i interface{}) time.Time {
-	return r.ptsReference.time.Add(time.Duration(astiav.RescaleQ(i.(*frameRateEmulatorItem).f.Pts()-r.ptsReference.pts, r.outputCtx.TimeBase, nanosecondRational)))
+	return r.ptsReference.time.Add(time.Duration(float64(astiav.RescaleQ(i.(*frameRateEmulatorItem).f.Pts()-r.ptsReference.pts, r.outputCtx.TimeBase, nanosecondRational)) / r.ptsReference.speed))
 }
 
 func (r *FrameRateEmulator) rateEmulatorBefore(a, b interface{}) bool {
 	return a.(*frameRateEmulatorItem).f.Pts() < b.(*frameRateEmulatorItem).f.Pts()
 }
 
-func (r *FrameRateEmulator) rateEmulatorExec(i interface{}) {
+func (r *FrameRateEmulator) rateEmulatorExec(i interface{}, at time.Time) {
+	// Frame would be dispatched out of order or is too late
+	if r.outOfOrder(i.(*frameRateEmulatorItem).f) || (r.dropLate && r.period > 0 && r.clock.Now().Sub(at) > r.period) {
+		// Increment dropped frames
+		atomic.AddUint64(&r.statFramesDropped, 1)
+
+		// Close frame
+		r.p.put(i.(*frameRateEmulatorItem).f)
+		return
+	}
+
 	// Dispatch
 	r.d.dispatch(i.(*frameRateEmulatorItem).f, i.(*frameRateEmulatorItem).d)
 
 	// Close frame
 	r.p.put(i.(*frameRateEmulatorItem).f)
 }
+
+func (r *FrameRateEmulator) outOfOrder(f *astiav.Frame) bool {
+	// Order is not enforced
+	if r.reorderWindow <= 0 || f.Pts() == astiav.NoPtsValue {
+		return false
+	}
+
+	// Frame is older than the last dispatched frame
+	if lastPTS := atomic.LoadInt64(&r.lastPTS); lastPTS != astiav.NoPtsValue && f.Pts() <= lastPTS {
+		return true
+	}
+
+	// Update last pts
+	atomic.StoreInt64(&r.lastPTS, f.Pts())
+	return false
+}
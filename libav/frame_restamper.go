@@ -1,6 +1,8 @@
 package astilibav
 
 import (
+	"sync"
+
 	"github.com/asticode/go-astiav"
 	"github.com/asticode/go-astikit"
 )
@@ -83,3 +85,98 @@ func (r *frameRestamperWithModulo) Restamp(f *astiav.Frame) {
 		return astikit.Int64Ptr(f.Pts() - (f.Pts() % r.frameDuration))
 	})
 }
+
+type frameRestamperWithOffset struct {
+	offset int64
+}
+
+// NewFrameRestamperWithOffset creates a new frame restamper that adds a fixed offset to PTS
+// Since frames don't hold their time base, offset is expressed in tb which must be the frame time base
+func NewFrameRestamperWithOffset(offset int64, tb astiav.Rational) FrameRestamper {
+	return &frameRestamperWithOffset{offset: offset}
+}
+
+// Restamp implements the FrameRestamper interface
+func (r *frameRestamperWithOffset) Restamp(f *astiav.Frame) {
+	f.SetPts(f.Pts() + r.offset)
+}
+
+// MonotonicFrameRestamper represents a frame restamper that makes sure PTS are strictly increasing
+type MonotonicFrameRestamper struct {
+	lastPTS *int64
+	m       *sync.Mutex
+}
+
+// NewMonotonicFrameRestamper creates a new frame restamper that bumps PTS lower than or equal to
+// the last PTS to the last PTS + 1
+func NewMonotonicFrameRestamper() *MonotonicFrameRestamper {
+	return &MonotonicFrameRestamper{m: &sync.Mutex{}}
+}
+
+// Restamp implements the FrameRestamper interface
+func (r *MonotonicFrameRestamper) Restamp(f *astiav.Frame) {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Bump PTS
+	if r.lastPTS != nil && f.Pts() <= *r.lastPTS {
+		f.SetPts(*r.lastPTS + 1)
+	}
+
+	// Store last PTS
+	r.lastPTS = astikit.Int64Ptr(f.Pts())
+}
+
+// Reset forgets the last PTS. It's useful after an intentional seek
+func (r *MonotonicFrameRestamper) Reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.lastPTS = nil
+}
+
+type audioSampleRestamper struct {
+	m              *sync.Mutex
+	sampleTimeBase astiav.Rational
+	samples        int64
+	timeBase       astiav.Rational
+}
+
+// NewAudioSampleRestamper creates a new frame restamper that sets PTS based on the cumulative
+// number of samples, rescaled to tb
+func NewAudioSampleRestamper(sampleRate int, tb astiav.Rational) FrameRestamper {
+	return &audioSampleRestamper{
+		m:              &sync.Mutex{},
+		sampleTimeBase: astiav.NewRational(1, sampleRate),
+		timeBase:       tb,
+	}
+}
+
+// Restamp implements the FrameRestamper interface
+func (r *audioSampleRestamper) Restamp(f *astiav.Frame) {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Restamp
+	f.SetPts(astiav.RescaleQ(r.samples, r.sampleTimeBase, r.timeBase))
+
+	// Increment samples
+	r.samples += int64(f.NbSamples())
+}
+
+type chainFrameRestamper struct {
+	rs []FrameRestamper
+}
+
+// NewChainFrameRestamper creates a new frame restamper that executes the provided restampers in order
+func NewChainFrameRestamper(rs ...FrameRestamper) FrameRestamper {
+	return &chainFrameRestamper{rs: rs}
+}
+
+// Restamp implements the FrameRestamper interface
+func (r *chainFrameRestamper) Restamp(f *astiav.Frame) {
+	for _, v := range r.rs {
+		v.Restamp(f)
+	}
+}
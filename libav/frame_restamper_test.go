@@ -54,3 +54,74 @@ func TestFrameRestamperWithModulo(t *testing.T) {
 		require.Equal(t, ft.output, f.Pts())
 	}
 }
+
+func TestFrameRestamperWithOffset(t *testing.T) {
+	f := astiav.AllocFrame()
+	require.NotNil(t, f)
+	defer f.Free()
+	r := NewFrameRestamperWithOffset(100, astiav.NewRational(1, 25))
+	for _, ft := range []frameTest{
+		{input: 0, output: 100},
+		{input: 1, output: 101},
+		{input: 5, output: 105},
+	} {
+		f.SetPts(ft.input)
+		r.Restamp(f)
+		require.Equal(t, ft.output, f.Pts())
+	}
+}
+
+func TestMonotonicFrameRestamper(t *testing.T) {
+	f := astiav.AllocFrame()
+	require.NotNil(t, f)
+	defer f.Free()
+	r := NewMonotonicFrameRestamper()
+	for _, ft := range []frameTest{
+		{input: 10, output: 10},
+		{input: 20, output: 20},
+		{input: 20, output: 21},
+		{input: 15, output: 22},
+		{input: 30, output: 30},
+	} {
+		f.SetPts(ft.input)
+		r.Restamp(f)
+		require.Equal(t, ft.output, f.Pts())
+	}
+	r.Reset()
+	f.SetPts(0)
+	r.Restamp(f)
+	require.Equal(t, int64(0), f.Pts())
+}
+
+func TestAudioSampleRestamper(t *testing.T) {
+	f := astiav.AllocFrame()
+	require.NotNil(t, f)
+	defer f.Free()
+	f.SetNbSamples(1024)
+	r := NewAudioSampleRestamper(48000, astiav.NewRational(1, 96000))
+	for _, ft := range []frameTest{
+		{input: 100, output: 0},
+		{input: 50, output: 2048},
+		{input: 5000, output: 4096},
+	} {
+		f.SetPts(ft.input)
+		r.Restamp(f)
+		require.Equal(t, ft.output, f.Pts())
+	}
+}
+
+func TestChainFrameRestamper(t *testing.T) {
+	f := astiav.AllocFrame()
+	require.NotNil(t, f)
+	defer f.Free()
+	r := NewChainFrameRestamper(NewFrameRestamperWithOffset(100, astiav.NewRational(1, 25)), NewMonotonicFrameRestamper())
+	for _, ft := range []frameTest{
+		{input: 0, output: 100},
+		{input: 5, output: 105},
+		{input: 5, output: 106},
+	} {
+		f.SetPts(ft.input)
+		r.Restamp(f)
+		require.Equal(t, ft.output, f.Pts())
+	}
+}
@@ -0,0 +1,80 @@
+package astilibav
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameDispatcherConnectUnderLoad(t *testing.T) {
+	for _, m := range []FrameDispatchMode{FrameDispatchModeSequential, FrameDispatchModeConcurrent} {
+		t.Run(string(m), func(t *testing.T) {
+			// Create dispatcher
+			eh := astiencoder.NewEventHandler()
+			c := astikit.NewCloser()
+			defer c.Close()
+			d := newFrameDispatcherWithOptions(newMockedFrameHandler(c, eh, func(p FrameHandlerPayload) {}), eh, FrameDispatcherOptions{Mode: m})
+
+			// Create handlers
+			var count uint64
+			var hs []*mockedFrameHandler
+			for i := 0; i < 10; i++ {
+				hs = append(hs, newNamedMockedFrameHandler(fmt.Sprintf("mocked_%d", i), c, eh, func(p FrameHandlerPayload) {
+					atomic.AddUint64(&count, 1)
+				}))
+			}
+
+			// Dispatch frames
+			f := astiav.AllocFrame()
+			defer f.Free()
+			stop := make(chan struct{})
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						d.dispatch(f, NewDescriptor(astiav.NewRational(1, 25)))
+					}
+				}
+			}()
+
+			// Connect and disconnect handlers while dispatching
+			for i := 0; i < 100; i++ {
+				for idx, h := range hs {
+					if idx%2 == 0 {
+						d.addHandler(h)
+					} else {
+						d.addHandlerWithRestamper(h, nil)
+					}
+				}
+				for _, h := range hs {
+					d.delHandler(h)
+				}
+			}
+
+			// Connected handler should receive frames
+			d.addHandler(hs[0])
+			before := atomic.LoadUint64(&count)
+			require.Eventually(t, func() bool { return atomic.LoadUint64(&count) > before }, time.Second, time.Millisecond)
+
+			// Disconnected handlers shouldn't receive frames once dispatching in progress is over
+			d.delHandler(hs[0])
+			close(stop)
+			wg.Wait()
+			before = atomic.LoadUint64(&count)
+			d.dispatch(f, NewDescriptor(astiav.NewRational(1, 25)))
+			require.Equal(t, before, atomic.LoadUint64(&count))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package astilibav
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/asticode/go-astiav"
@@ -21,3 +23,51 @@ func durationToTimeBase(d time.Duration, t astiav.Rational) (i int64, r time.Dur
 func emitError(target interface{}, eh *astiencoder.EventHandler, err error, format string, args ...interface{}) {
 	eh.Emit(astiencoder.EventError(target, fmt.Errorf("astilibav: "+format+" failed: %w", append(args, err)...)))
 }
+
+const (
+	ioContextBufferSize = 4096
+	// Equivalent of AVSEEK_SIZE
+	ioContextSeekSize = 0x10000
+	// Equivalent of AVSEEK_FORCE
+	ioContextSeekForce = 0x20000
+)
+
+func newIOContextSeekFunc(i interface{}) astiav.IOContextSeekFunc {
+	return func(offset int64, whence int) (n int64, err error) {
+		// Not seekable
+		s, ok := i.(io.Seeker)
+		if !ok {
+			err = errors.New("astilibav: not seekable")
+			return
+		}
+
+		// Remove force flag
+		whence &^= ioContextSeekForce
+
+		// Size has been requested
+		if whence&ioContextSeekSize > 0 {
+			// Get current position
+			var cur int64
+			if cur, err = s.Seek(0, io.SeekCurrent); err != nil {
+				err = fmt.Errorf("astilibav: getting current position failed: %w", err)
+				return
+			}
+
+			// Get size
+			if n, err = s.Seek(0, io.SeekEnd); err != nil {
+				err = fmt.Errorf("astilibav: seeking to end failed: %w", err)
+				return
+			}
+
+			// Seek back to current position
+			if _, err = s.Seek(cur, io.SeekStart); err != nil {
+				err = fmt.Errorf("astilibav: seeking back to current position failed: %w", err)
+				return
+			}
+			return
+		}
+
+		// Seek
+		return s.Seek(offset, whence)
+	}
+}
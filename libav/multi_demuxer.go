@@ -0,0 +1,116 @@
+package astilibav
+
+import (
+	"fmt"
+
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+// MultiDemuxer groups several demuxers (e.g. a video file and an external subtitle file) and
+// exposes their streams with globally unique indexes so that a single muxer can combine them.
+// Demuxers are regular nodes: they still need to be added to the workflow using Demuxers()
+type MultiDemuxer struct {
+	ds []*Demuxer
+	ss []*multiDemuxerStream
+}
+
+type multiDemuxerStream struct {
+	d *Demuxer
+	// Stream as returned by the demuxer
+	i *Stream
+	// Stream with its global index
+	o *Stream
+}
+
+// MultiDemuxerOptions represents multi demuxer options
+type MultiDemuxerOptions struct {
+	Inputs []DemuxerOptions
+}
+
+// NewMultiDemuxer creates a new multi demuxer
+func NewMultiDemuxer(o MultiDemuxerOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (m *MultiDemuxer, err error) {
+	// Create multi demuxer
+	m = &MultiDemuxer{}
+
+	// Loop through inputs
+	for idx, i := range o.Inputs {
+		// Create demuxer
+		var d *Demuxer
+		if d, err = NewDemuxer(i, eh, c, s); err != nil {
+			err = fmt.Errorf("astilibav: creating demuxer for input #%d failed: %w", idx, err)
+			return
+		}
+
+		// Store demuxer
+		m.ds = append(m.ds, d)
+
+		// Loop through streams
+		for _, is := range d.Streams() {
+			// Create stream with global index
+			os := *is
+			os.Index = len(m.ss)
+
+			// Store stream
+			m.ss = append(m.ss, &multiDemuxerStream{
+				d: d,
+				i: is,
+				o: &os,
+			})
+		}
+	}
+	return
+}
+
+// Demuxers returns the underlying demuxers
+func (m *MultiDemuxer) Demuxers() []*Demuxer {
+	return m.ds
+}
+
+// Streams returns the streams of all demuxers with their global index
+func (m *MultiDemuxer) Streams() (ss []*Stream) {
+	for _, s := range m.ss {
+		ss = append(ss, s.o)
+	}
+	return
+}
+
+func (m *MultiDemuxer) stream(i *Stream) (s *multiDemuxerStream, err error) {
+	// Invalid index
+	if i.Index < 0 || i.Index >= len(m.ss) {
+		err = fmt.Errorf("astilibav: stream index %d is invalid", i.Index)
+		return
+	}
+	s = m.ss[i.Index]
+	return
+}
+
+// ConnectForStream connects the demuxer owning the stream to a PktHandler for that specific stream.
+// The stream must have been returned by Streams()
+func (m *MultiDemuxer) ConnectForStream(h PktHandler, i *Stream) (err error) {
+	// Get stream
+	var s *multiDemuxerStream
+	if s, err = m.stream(i); err != nil {
+		err = fmt.Errorf("astilibav: getting stream failed: %w", err)
+		return
+	}
+
+	// Connect
+	s.d.ConnectForStream(h, s.i)
+	return
+}
+
+// DisconnectForStream disconnects the demuxer owning the stream from a PktHandler for that specific
+// stream. The stream must have been returned by Streams()
+func (m *MultiDemuxer) DisconnectForStream(h PktHandler, i *Stream) (err error) {
+	// Get stream
+	var s *multiDemuxerStream
+	if s, err = m.stream(i); err != nil {
+		err = fmt.Errorf("astilibav: getting stream failed: %w", err)
+		return
+	}
+
+	// Disconnect
+	s.d.DisconnectForStream(h, s.i)
+	return
+}
@@ -2,7 +2,11 @@ package astilibav
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,11 +22,20 @@ var countMuxer uint64
 type Muxer struct {
 	*astiencoder.BaseNode
 	c                    *astikit.Chan
+	dictionary           *Dictionary
+	eos                  *eosTracker
 	formatContext        *astiav.FormatContext
 	eh                   *astiencoder.EventHandler
+	format               *astiav.OutputFormat
+	formatName           string
+	maxInterleaveDelta   time.Duration
 	o                    *sync.Once
+	oc                   *astikit.Closer
+	ot                   *sync.Once // Writes trailer once
 	p                    *pktPool
 	restamper            PktRestamper
+	sg                   *muxerSegmenter
+	writer               io.Writer
 	statBytesWritten     uint64
 	statPacketsProcessed uint64
 	statPacketsReceived  uint64
@@ -30,11 +43,102 @@ type Muxer struct {
 
 // MuxerOptions represents muxer options
 type MuxerOptions struct {
+	// String content of the muxer as you would use in ffmpeg
+	// It's used when writing the header
+	Dictionary *Dictionary
 	Format     *astiav.OutputFormat
 	FormatName string
-	Node       astiencoder.NodeOptions
-	Restamper  PktRestamper
-	URL        string
+	// Maximum buffering duration for interleaving packets across streams.
+	// Defaults to FFmpeg's default
+	MaxInterleaveDelta time.Duration
+	Node               astiencoder.NodeOptions
+	Restamper          PktRestamper
+	// Segmenter options
+	// When enabled, URL is ignored
+	Segmenter MuxerSegmenterOptions
+	URL       string
+	// If provided, the output is written to the writer instead of URL.
+	// If the writer doesn't implement io.Seeker, the output is considered as non-seekable and
+	// packets are flushed as soon as possible
+	Writer io.Writer
+}
+
+// Muxer will split its output into segments starting on video keyframes
+type MuxerSegmenterOptions struct {
+	// Minimum duration of a segment
+	Duration time.Duration
+	Enabled  bool
+	// Pattern used to generate segment filenames where "%d" is replaced with the segment index
+	// and "%Y", "%m", "%H", "%M" and "%S" with the segment creation time
+	Filename string
+	// Executed once a segment has been closed
+	OnSegmentClosed func(s MuxerSegment)
+	// If true, timestamps are restamped so that each segment starts near zero
+	Restamp bool
+}
+
+// MuxerSegment represents a segment that has been closed
+type MuxerSegment struct {
+	Duration time.Duration
+	Filename string
+	Index    int
+}
+
+type muxerSegmenter struct {
+	duration        time.Duration
+	filename        string
+	idx             int
+	onSegmentClosed func(s MuxerSegment)
+	restamp         bool
+	segment         *muxerSegment
+}
+
+type muxerSegment struct {
+	filename string
+	idx      int
+	// In nanoseconds
+	lastPTS  *int64
+	startPTS *int64
+}
+
+func newMuxerSegmenter(o MuxerSegmenterOptions) *muxerSegmenter {
+	return &muxerSegmenter{
+		duration:        o.Duration,
+		filename:        o.Filename,
+		onSegmentClosed: o.OnSegmentClosed,
+		restamp:         o.Restamp,
+	}
+}
+
+func (s *muxerSegmenter) next() *muxerSegment {
+	// Increment index
+	s.idx++
+
+	// Create segment
+	n := time.Now()
+	s.segment = &muxerSegment{
+		filename: strings.NewReplacer(
+			"%d", strconv.Itoa(s.idx),
+			"%Y", n.Format("2006"),
+			"%m", n.Format("01"),
+			"%H", n.Format("15"),
+			"%M", n.Format("04"),
+			"%S", n.Format("05"),
+		).Replace(s.filename),
+		idx: s.idx,
+	}
+	return s.segment
+}
+
+func (s *muxerSegment) muxerSegment() MuxerSegment {
+	ms := MuxerSegment{
+		Filename: s.filename,
+		Index:    s.idx,
+	}
+	if s.startPTS != nil && s.lastPTS != nil {
+		ms.Duration = time.Duration(*s.lastPTS - *s.startPTS)
+	}
+	return ms
 }
 
 // NewMuxer creates a new muxer
@@ -45,43 +149,100 @@ func NewMuxer(o MuxerOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s
 
 	// Create muxer
 	m = &Muxer{
-		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
-		eh:        eh,
-		o:         &sync.Once{},
-		restamper: o.Restamper,
+		c:                  astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		dictionary:         o.Dictionary,
+		eh:                 eh,
+		format:             o.Format,
+		formatName:         o.FormatName,
+		maxInterleaveDelta: o.MaxInterleaveDelta,
+		o:                  &sync.Once{},
+		ot:                 &sync.Once{},
+		restamper:          o.Restamper,
+		writer:             o.Writer,
+	}
+
+	// Create segmenter
+	if o.Segmenter.Enabled {
+		m.sg = newMuxerSegmenter(o.Segmenter)
 	}
 
 	// Create base node
 	m.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, m, astiencoder.EventTypeToNodeEventName)
 
+	// Create end of stream tracker
+	m.eos = newEOSTracker(m)
+
 	// Create pkt pool
 	m.p = newPktPool(m)
 
 	// Add stat options
 	m.addStatOptions()
 
+	// Make sure the output is properly closed
+	m.AddCloseWithError(m.closeOutput)
+
+	// Get url
+	url := o.URL
+	if m.sg != nil {
+		url = m.sg.next().filename
+	}
+
+	// Open output
+	if err = m.openOutput(url); err != nil {
+		err = fmt.Errorf("astilibav: opening output failed: %w", err)
+		return
+	}
+	return
+}
+
+func (m *Muxer) openOutput(url string) (err error) {
+	// Create output closer
+	m.oc = astikit.NewCloser()
+
 	// Alloc format context
-	if m.formatContext, err = astiav.AllocOutputFormatContext(o.Format, o.FormatName, o.URL); err != nil {
+	if m.formatContext, err = astiav.AllocOutputFormatContext(m.format, m.formatName, url); err != nil {
 		err = fmt.Errorf("astilibav: allocating output format context failed: %w", err)
 		return
 	}
 
 	// Make sure the format context is properly closed
-	m.AddClose(m.formatContext.Free)
+	m.oc.Add(m.formatContext.Free)
+
+	// Handle writer
+	if m.writer != nil {
+		// Get seek func
+		var seekFunc astiav.IOContextSeekFunc
+		if _, ok := m.writer.(io.Seeker); ok {
+			seekFunc = newIOContextSeekFunc(m.writer)
+		} else {
+			m.formatContext.SetFlags(m.formatContext.Flags().Add(astiav.FormatContextFlagFlushPackets))
+		}
+
+		// Alloc io context
+		var ioContext *astiav.IOContext
+		if ioContext, err = astiav.AllocIOContext(ioContextBufferSize, nil, seekFunc, m.writer.Write); err != nil {
+			err = fmt.Errorf("astilibav: allocating io context failed: %w", err)
+			return
+		}
+
+		// Make sure the io context is properly freed
+		m.oc.Add(ioContext.Free)
 
-	// We need to use an io context if this is a file
-	if !m.formatContext.OutputFormat().Flags().Has(astiav.IOFormatFlagNofile) {
+		// Set pb
+		m.formatContext.SetPb(ioContext)
+	} else if !m.formatContext.OutputFormat().Flags().Has(astiav.IOFormatFlagNofile) {
+		// We need to use an io context if this is a file
 		// Create io context
 		ioContext := astiav.NewIOContext()
 
 		// Open
-		if err = ioContext.Open(o.URL, astiav.NewIOContextFlags(astiav.IOContextFlagWrite)); err != nil {
+		if err = ioContext.Open(url, astiav.NewIOContextFlags(astiav.IOContextFlagWrite)); err != nil {
 			err = fmt.Errorf("astilibav: opening io context failed: %w", err)
 			return
 		}
 
 		// Make sure the io context is properly closed
-		m.AddCloseWithError(func() error {
+		m.oc.AddWithError(func() error {
 			if err := ioContext.Closep(); err != nil {
 				return fmt.Errorf("astilibav: closing io context failed: %w", err)
 			}
@@ -94,6 +255,71 @@ func NewMuxer(o MuxerOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s
 	return
 }
 
+func (m *Muxer) closeOutput() (err error) {
+	// Close
+	if err = m.oc.Close(); err != nil {
+		return
+	}
+
+	// Segment has been closed
+	if m.sg != nil && m.sg.onSegmentClosed != nil && m.sg.segment.startPTS != nil {
+		m.sg.onSegmentClosed(m.sg.segment.muxerSegment())
+	}
+	return
+}
+
+func (m *Muxer) nextSegment() (err error) {
+	// Write trailer
+	if err = m.formatContext.WriteTrailer(); err != nil {
+		err = fmt.Errorf("astilibav: writing trailer failed: %w", err)
+		return
+	}
+
+	// Store previous output
+	prevFormatContext := m.formatContext
+	prevOutputCloser := m.oc
+	prevSegment := m.sg.segment
+
+	// Open output
+	if err = m.openOutput(m.sg.next().filename); err != nil {
+		err = fmt.Errorf("astilibav: opening output failed: %w", err)
+		return
+	}
+
+	// Clone streams
+	for _, ps := range prevFormatContext.Streams() {
+		// Add stream
+		s := AddStream(m.formatContext)
+
+		// Copy codec parameters
+		if err = ps.CodecParameters().Copy(s.CodecParameters()); err != nil {
+			err = fmt.Errorf("astilibav: copying codec parameters failed: %w", err)
+			return
+		}
+
+		// Set time base
+		s.SetTimeBase(ps.TimeBase())
+	}
+
+	// Close previous output
+	if err = prevOutputCloser.Close(); err != nil {
+		err = fmt.Errorf("astilibav: closing previous output failed: %w", err)
+		return
+	}
+
+	// Segment has been closed
+	if m.sg.onSegmentClosed != nil {
+		m.sg.onSegmentClosed(prevSegment.muxerSegment())
+	}
+
+	// Write header
+	if err = m.writeHeader(); err != nil {
+		err = fmt.Errorf("astilibav: writing header failed: %w", err)
+		return
+	}
+	return
+}
+
 type MuxerStats struct {
 	BytesWritten     uint64
 	PacketsAllocated uint64
@@ -154,24 +380,37 @@ func (m *Muxer) FormatContext() *astiav.FormatContext {
 	return m.formatContext
 }
 
+// StreamContext returns the context of the output stream with the provided index
+// Since FFmpeg may update the stream timebase when writing the header, the final timebase
+// is only available once the muxer has been started
+func (m *Muxer) StreamContext(streamIndex int) (ctx Context, err error) {
+	// Loop through streams
+	for _, s := range m.formatContext.Streams() {
+		if s.Index() == streamIndex {
+			ctx = NewContextFromStream(s)
+			return
+		}
+	}
+	err = fmt.Errorf("astilibav: stream %d doesn't exist", streamIndex)
+	return
+}
+
 // Start starts the muxer
 func (m *Muxer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 	m.BaseNode.Start(ctx, t, func(t *astikit.Task) {
 		// Make sure to write header once
 		var err error
-		m.o.Do(func() { err = m.formatContext.WriteHeader(nil) })
+		m.o.Do(func() { err = m.writeHeader() })
 		if err != nil {
 			emitError(m, m.eh, err, "writing header")
 			return
 		}
 
 		// Write trailer once everything is done
-		m.AddCloseWithError(func() error {
-			if err := m.formatContext.WriteTrailer(); err != nil {
-				return fmt.Errorf("writing trailer failed: %w", err)
-			}
-			return nil
-		})
+		// It also flushes packets buffered for interleaving
+		// Since close funcs are executed in LIFO order, this is executed before the output is closed
+		// It may have already been written once all parents have reached end of stream
+		m.AddCloseWithError(m.writeTrailer)
 
 		// Make sure to stop the chan properly
 		defer m.c.Stop()
@@ -181,20 +420,225 @@ func (m *Muxer) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
 	})
 }
 
+func (m *Muxer) writeTrailer() (err error) {
+	m.ot.Do(func() {
+		if err = m.formatContext.WriteTrailer(); err != nil {
+			err = fmt.Errorf("astilibav: writing trailer failed: %w", err)
+		}
+	})
+	return
+}
+
+func (m *Muxer) writeHeader() (err error) {
+	// Dictionary
+	var dict *astiav.Dictionary
+	if m.dictionary != nil {
+		// Parse dict
+		if dict, err = m.dictionary.parse(); err != nil {
+			err = fmt.Errorf("astilibav: parsing dict failed: %w", err)
+			return
+		}
+	} else {
+		// Create dict
+		dict = astiav.NewDictionary()
+	}
+
+	// Make sure the dictionary is freed
+	defer dict.Free()
+
+	// Max interleave delta is expressed in microseconds
+	if m.maxInterleaveDelta > 0 {
+		if err = dict.Set("max_interleave_delta", strconv.FormatInt(m.maxInterleaveDelta.Microseconds(), 10), 0); err != nil {
+			err = fmt.Errorf("astilibav: setting max interleave delta failed: %w", err)
+			return
+		}
+	}
+
+	// Write header
+	if err = m.formatContext.WriteHeader(dict); err != nil {
+		// Format may require seeking
+		if _, ok := m.writer.(io.Seeker); m.writer != nil && !ok {
+			err = fmt.Errorf("astilibav: writer is not seekable and format may require seeking: %w", err)
+		}
+		return
+	}
+	return
+}
+
 // MuxerPktHandler is an object that can handle a pkt for the muxer
 type MuxerPktHandler struct {
 	*Muxer
-	o *astiav.Stream
+	idx int
+	o   *astiav.Stream
 }
 
 // NewHandler creates
 func (m *Muxer) NewPktHandler(o *astiav.Stream) *MuxerPktHandler {
 	return &MuxerPktHandler{
 		Muxer: m,
+		idx:   o.Index(),
 		o:     o,
 	}
 }
 
+func (h *MuxerPktHandler) stream() *astiav.Stream {
+	// Streams are recreated for each segment
+	if h.sg != nil && h.sg.idx > 1 {
+		return h.formatContext.Streams()[h.idx]
+	}
+	return h.o
+}
+
+// AddStream adds a stream to the output and returns its pkt handler
+// Stream's codec parameters must be set before the muxer is started
+func (m *Muxer) AddStream() *MuxerPktHandler {
+	return m.NewPktHandler(AddStream(m.formatContext))
+}
+
+// MuxerStreamSource represents a node whose packets can be muxed, such as an encoder
+type MuxerStreamSource interface {
+	OutputContexter
+	PktHandlerConnector
+}
+
+// ConnectSource adds an output stream matching the source, connects the source to it and returns
+// the stream index. It must be called before the muxer is started.
+// The stream's codec parameters are taken from the source's codec context or codec parameters if it
+// exposes them (e.g. Encoder or BitstreamFilter), and are derived from its output ctx otherwise
+func (m *Muxer) ConnectSource(src MuxerStreamSource) (idx int, err error) {
+	// Muxer has already been started
+	if m.Status() != astiencoder.StatusCreated {
+		err = errors.New("astilibav: streams can't be added once the muxer has been started")
+		return
+	}
+
+	// Add stream
+	var o *astiav.Stream
+	if o, err = m.addSourceStream(src); err != nil {
+		err = fmt.Errorf("astilibav: adding stream failed: %w", err)
+		return
+	}
+
+	// Connect
+	src.Connect(m.NewPktHandler(o))
+	idx = o.Index()
+	return
+}
+
+func (m *Muxer) addSourceStream(src MuxerStreamSource) (o *astiav.Stream, err error) {
+	// Source can add the stream itself
+	if v, ok := src.(interface {
+		AddStream(formatCtx *astiav.FormatContext) (*astiav.Stream, error)
+	}); ok {
+		return v.AddStream(m.formatContext)
+	}
+
+	// Add stream
+	ctx := src.OutputCtx()
+	o = AddStream(m.formatContext)
+	o.SetTimeBase(ctx.TimeBase)
+
+	// Source exposes its codec parameters
+	if v, ok := src.(interface {
+		OutputCodecParameters() *astiav.CodecParameters
+	}); ok {
+		// Copy codec parameters
+		if err = v.OutputCodecParameters().Copy(o.CodecParameters()); err != nil {
+			err = fmt.Errorf("astilibav: copying codec parameters failed: %w", err)
+			return
+		}
+
+		// Reset codec tag
+		o.CodecParameters().SetCodecTag(0)
+		return
+	}
+
+	// Set codec parameters based on the output ctx
+	cp := o.CodecParameters()
+	cp.SetBitRate(ctx.BitRate)
+	cp.SetCodecID(ctx.CodecID)
+	cp.SetCodecType(ctx.MediaType)
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		cp.SetChannelLayout(ctx.ChannelLayout)
+		cp.SetFrameSize(ctx.FrameSize)
+		cp.SetSampleFormat(ctx.SampleFormat)
+		cp.SetSampleRate(ctx.SampleRate)
+	case astiav.MediaTypeVideo:
+		cp.SetHeight(ctx.Height)
+		cp.SetPixelFormat(ctx.PixelFormat)
+		cp.SetSampleAspectRatio(ctx.SampleAspectRatio)
+		cp.SetWidth(ctx.Width)
+	}
+	return
+}
+
+// Stream returns the output stream
+func (h *MuxerPktHandler) Stream() *astiav.Stream {
+	return h.stream()
+}
+
+func (h *MuxerPktHandler) segment(pkt *astiav.Packet, o *astiav.Stream) (*astiav.Stream, error) {
+	// Invalid timestamps
+	if pkt.Pts() == astiav.NoPtsValue {
+		return o, nil
+	}
+
+	// Get pts in nanoseconds
+	pts := astiav.RescaleQ(pkt.Pts(), o.TimeBase(), nanosecondRational)
+
+	// Segment duration has been reached on a video keyframe
+	if h.sg.segment.startPTS != nil && o.CodecParameters().MediaType() == astiav.MediaTypeVideo &&
+		pkt.Flags().Has(astiav.PacketFlagKey) && time.Duration(pts-*h.sg.segment.startPTS) >= h.sg.duration {
+		// Next segment
+		if err := h.nextSegment(); err != nil {
+			return o, fmt.Errorf("astilibav: starting next segment failed: %w", err)
+		}
+
+		// Get new output stream
+		o = h.stream()
+	}
+
+	// Update segment
+	if h.sg.segment.startPTS == nil {
+		h.sg.segment.startPTS = astikit.Int64Ptr(pts)
+	}
+	h.sg.segment.lastPTS = astikit.Int64Ptr(pts)
+
+	// Restamp
+	if h.sg.restamp {
+		d := astiav.RescaleQ(*h.sg.segment.startPTS, nanosecondRational, o.TimeBase())
+		if pkt.Dts() != astiav.NoPtsValue {
+			pkt.SetDts(pkt.Dts() - d)
+		}
+		pkt.SetPts(pkt.Pts() - d)
+	}
+	return o, nil
+}
+
+// HandleEOS implements the EOSHandler interface
+func (m *Muxer) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	m.DoWhenUnclosed(func() {
+		// Add to chan
+		m.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			m.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !m.eos.add(p.Node) {
+					return
+				}
+
+				// Write trailer
+				// Packets are not buffered for interleaving anymore once it's written
+				if err := m.writeTrailer(); err != nil {
+					emitError(m, m.eh, err, "writing trailer")
+				}
+			})
+		})
+	})
+}
+
 // HandlePkt implements the PktHandler interface
 func (h *MuxerPktHandler) HandlePkt(p PktHandlerPayload) {
 	// Everything executed outside the main loop should be protected from the closer
@@ -222,11 +666,25 @@ func (h *MuxerPktHandler) HandlePkt(p PktHandlerPayload) {
 				// Increment processed packets
 				atomic.AddUint64(&h.statPacketsProcessed, 1)
 
-				// Rescale timestamps
-				pkt.RescaleTs(p.Descriptor.TimeBase(), h.o.TimeBase())
+				// Get output stream
+				o := h.stream()
+
+				// Rescale timestamps and duration
+				// Output stream timebase must be retrieved here since it may have been updated
+				// when writing the header
+				pkt.RescaleTs(p.Descriptor.TimeBase(), o.TimeBase())
 
 				// Set stream index
-				pkt.SetStreamIndex(h.o.Index())
+				pkt.SetStreamIndex(o.Index())
+
+				// Segment
+				if h.sg != nil {
+					var err error
+					if o, err = h.segment(pkt, o); err != nil {
+						emitError(h, h.eh, err, "segmenting")
+						return
+					}
+				}
 
 				// Restamp
 				if h.restamper != nil {
@@ -30,76 +30,372 @@ type PktHandlerPayload struct {
 	Pkt        *astiav.Packet
 }
 
+// PktDispatchStrategy represents the way packets are dispatched to handlers that can't keep up
+type PktDispatchStrategy string
+
+// Pkt dispatch strategies
+const (
+	// Handlers are called synchronously, therefore a slow handler blocks the dispatching node
+	PktDispatchStrategyBlock PktDispatchStrategy = "block"
+	// Packets are buffered per handler and the oldest buffered packet is dropped when the buffer is full
+	PktDispatchStrategyDropOldest PktDispatchStrategy = "drop_oldest"
+	// Packets are buffered per handler and the incoming packet is dropped when the buffer is full
+	PktDispatchStrategyDropNewest PktDispatchStrategy = "drop_newest"
+)
+
+// PktDispatcherOptions represents pkt dispatcher options
+type PktDispatcherOptions struct {
+	// Number of packets buffered per handler when dropping.
+	// Defaults to 100
+	BufferSize int
+	// Defaults to PktDispatchStrategyBlock
+	Strategy PktDispatchStrategy
+}
+
+// Handlers can be added or deleted while the node is dispatching: each mutation rebuilds an immutable
+// snapshot of targets that dispatching reads without locking (copy-on-write). A handler deleted while
+// a packet is being dispatched may still receive that packet
 type pktDispatcher struct {
 	eh                    *astiencoder.EventHandler
 	hs                    map[string]PktHandler
-	m                     *sync.Mutex
+	m                     *sync.Mutex // Locks hs, qs and ts updates
 	n                     astiencoder.Node
+	o                     PktDispatcherOptions
+	p                     *pktPool
+	qs                    map[string]*pktDispatcherQueue
 	statPacketsDispatched uint64
+	statPacketsDropped    uint64
+	ts                    atomic.Value // Stores []pktDispatcherTarget
+}
+
+type pktDispatcherTarget struct {
+	h PktHandler
+	q *pktDispatcherQueue
 }
 
 func newPktDispatcher(n astiencoder.Node, eh *astiencoder.EventHandler) *pktDispatcher {
-	return &pktDispatcher{
+	return newPktDispatcherWithOptions(n, eh, PktDispatcherOptions{})
+}
+
+func newPktDispatcherWithOptions(n astiencoder.Node, eh *astiencoder.EventHandler, o PktDispatcherOptions) (d *pktDispatcher) {
+	// Default options
+	if o.Strategy == "" {
+		o.Strategy = PktDispatchStrategyBlock
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = 100
+	}
+
+	// Create dispatcher
+	d = &pktDispatcher{
 		eh: eh,
 		hs: make(map[string]PktHandler),
 		m:  &sync.Mutex{},
 		n:  n,
+		o:  o,
+		qs: make(map[string]*pktDispatcherQueue),
+	}
+
+	// Store targets
+	d.ts.Store([]pktDispatcherTarget{})
+
+	// Packets are buffered
+	if d.drops() {
+		// Create pkt pool
+		d.p = newPktPool(n)
+
+		// Make sure queues are stopped
+		n.AddClose(d.close)
 	}
+	return
+}
+
+func (d *pktDispatcher) drops() bool {
+	return d.o.Strategy == PktDispatchStrategyDropNewest || d.o.Strategy == PktDispatchStrategyDropOldest
+}
+
+func (d *pktDispatcher) close() {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for n, q := range d.qs {
+		q.stop()
+		delete(d.qs, n)
+	}
+	d.updateTargets()
 }
 
 func (d *pktDispatcher) addHandler(h PktHandler) {
 	d.m.Lock()
 	defer d.m.Unlock()
 	d.hs[h.Metadata().Name] = h
+
+	// Create queue
+	if d.drops() {
+		if _, ok := d.qs[h.Metadata().Name]; !ok {
+			d.qs[h.Metadata().Name] = d.newQueue(h)
+		}
+	}
+	d.updateTargets()
 }
 
 func (d *pktDispatcher) delHandler(h PktHandler) {
 	d.m.Lock()
 	defer d.m.Unlock()
 	delete(d.hs, h.Metadata().Name)
+
+	// Stop queue
+	if q, ok := d.qs[h.Metadata().Name]; ok {
+		q.stop()
+		delete(d.qs, h.Metadata().Name)
+	}
+	d.updateTargets()
 }
 
-func (d *pktDispatcher) dispatch(pkt *astiav.Packet, descriptor Descriptor) {
-	// Increment dispatched packets
-	atomic.AddUint64(&d.statPacketsDispatched, 1)
+// Assumes the mutex is locked
+func (d *pktDispatcher) updateTargets() {
+	// Create targets
+	ts := make([]pktDispatcherTarget, 0, len(d.hs))
+	for n, h := range d.hs {
+		ts = append(ts, pktDispatcherTarget{
+			h: h,
+			q: d.qs[n],
+		})
+	}
 
-	// Get handlers
-	d.m.Lock()
-	var hs []PktHandler
-	for _, h := range d.hs {
-		v, ok := h.(PktCond)
-		if !ok || v.UsePkt(pkt) {
-			hs = append(hs, h)
+	// Store targets
+	// A new slice is stored so that dispatching never sees it change
+	d.ts.Store(ts)
+}
+
+type pktDispatcherQueue struct {
+	c       chan pktDispatcherQueueItem
+	d       *pktDispatcher
+	h       PktHandler
+	m       *sync.Mutex // Locks stopped
+	q       chan struct{}
+	stopped bool
+}
+
+type pktDispatcherQueueItem struct {
+	descriptor Descriptor
+	eos        bool
+	pkt        *astiav.Packet
+}
+
+func (d *pktDispatcher) newQueue(h PktHandler) (q *pktDispatcherQueue) {
+	// Create queue
+	q = &pktDispatcherQueue{
+		c: make(chan pktDispatcherQueueItem, d.o.BufferSize),
+		d: d,
+		h: h,
+		m: &sync.Mutex{},
+		q: make(chan struct{}),
+	}
+
+	// Start queue
+	go q.start()
+	return
+}
+
+func (q *pktDispatcherQueue) start() {
+	for {
+		select {
+		case i := <-q.c:
+			// Handle end of stream
+			if i.eos {
+				if v, ok := q.h.(EOSHandler); ok {
+					v.HandleEOS(EOSHandlerPayload{Node: q.d.n})
+				}
+				continue
+			}
+
+			// Handle pkt
+			q.h.HandlePkt(PktHandlerPayload{
+				Descriptor: i.descriptor,
+				Node:       q.d.n,
+				Pkt:        i.pkt,
+			})
+
+			// Make sure to close pkt
+			q.d.p.put(i.pkt)
+		case <-q.q:
+			// Make sure to close remaining pkts
+			for {
+				select {
+				case i := <-q.c:
+					if i.pkt != nil {
+						q.d.p.put(i.pkt)
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *pktDispatcherQueue) stop() {
+	// Lock
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	// Queue is already stopped
+	if q.stopped {
+		return
+	}
+
+	// Stop
+	// Since adding is locked as well, remaining pkts are all in the chan when it's drained
+	q.stopped = true
+	close(q.q)
+}
+
+func (q *pktDispatcherQueue) add(pkt *astiav.Packet, descriptor Descriptor) {
+	// Copy pkt
+	i := pktDispatcherQueueItem{
+		descriptor: descriptor,
+		pkt:        q.d.p.get(),
+	}
+	if err := i.pkt.Ref(pkt); err != nil {
+		q.d.p.put(i.pkt)
+		emitError(q.d.n, q.d.eh, err, "refing packet")
+		return
+	}
+
+	// Lock
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	// Queue has been stopped after targets were retrieved
+	if q.stopped {
+		q.d.p.put(i.pkt)
+		return
+	}
+
+	// Loop
+	for {
+		// Add item
+		select {
+		case q.c <- i:
+			return
+		default:
+		}
+
+		// Drop newest
+		if q.d.o.Strategy == PktDispatchStrategyDropNewest {
+			atomic.AddUint64(&q.d.statPacketsDropped, 1)
+			q.d.p.put(i.pkt)
+			return
+		}
+
+		// Drop oldest
+		select {
+		case o := <-q.c:
+			atomic.AddUint64(&q.d.statPacketsDropped, 1)
+			q.d.p.put(o.pkt)
+		default:
 		}
 	}
-	d.m.Unlock()
+}
+
+// addEOS adds end of stream after buffered pkts. Since it must not be dropped, the oldest buffered
+// pkt is dropped if the queue is full, whatever the strategy
+func (q *pktDispatcherQueue) addEOS() {
+	// Lock
+	q.m.Lock()
+	defer q.m.Unlock()
 
-	// No handlers
-	if len(hs) == 0 {
+	// Queue has been stopped
+	if q.stopped {
 		return
 	}
 
+	// Loop
+	for {
+		// Add item
+		select {
+		case q.c <- pktDispatcherQueueItem{eos: true}:
+			return
+		default:
+		}
+
+		// Drop oldest
+		select {
+		case o := <-q.c:
+			if o.pkt != nil {
+				atomic.AddUint64(&q.d.statPacketsDropped, 1)
+				q.d.p.put(o.pkt)
+			}
+		default:
+		}
+	}
+}
+
+// dispatchEOS lets handlers know the node has reached end of stream
+func (d *pktDispatcher) dispatchEOS() {
+	for _, t := range d.ts.Load().([]pktDispatcherTarget) {
+		// Handler doesn't handle end of stream
+		if _, ok := t.h.(EOSHandler); !ok {
+			continue
+		}
+
+		// End of stream is buffered
+		if t.q != nil {
+			t.q.addEOS()
+			continue
+		}
+
+		// Handle end of stream
+		t.h.(EOSHandler).HandleEOS(EOSHandlerPayload{Node: d.n})
+	}
+}
+
+func (d *pktDispatcher) dispatch(pkt *astiav.Packet, descriptor Descriptor) {
+	// Increment dispatched packets
+	atomic.AddUint64(&d.statPacketsDispatched, 1)
+
+	// Get targets
+	ts := d.ts.Load().([]pktDispatcherTarget)
+
+	// Loop through queues first since adding to them doesn't block
+	for _, t := range ts {
+		if t.q != nil && pktDispatcherTargetUsesPkt(t, pkt) {
+			t.q.add(pkt, descriptor)
+		}
+	}
+
 	// Loop through handlers
-	for _, h := range hs {
-		// Handle pkt
-		h.HandlePkt(PktHandlerPayload{
-			Descriptor: descriptor,
-			Node:       d.n,
-			Pkt:        pkt,
-		})
+	for _, t := range ts {
+		if t.q == nil && pktDispatcherTargetUsesPkt(t, pkt) {
+			// Handle pkt
+			t.h.HandlePkt(PktHandlerPayload{
+				Descriptor: descriptor,
+				Node:       d.n,
+				Pkt:        pkt,
+			})
+		}
 	}
 }
 
+func pktDispatcherTargetUsesPkt(t pktDispatcherTarget, pkt *astiav.Packet) bool {
+	v, ok := t.h.(PktCond)
+	return !ok || v.UsePkt(pkt)
+}
+
 type pktDispatcherStats struct {
 	packetsDispatched uint64
+	packetsDropped    uint64
 }
 
 func (d *pktDispatcher) stats() pktDispatcherStats {
-	return pktDispatcherStats{packetsDispatched: atomic.LoadUint64(&d.statPacketsDispatched)}
+	return pktDispatcherStats{
+		packetsDispatched: atomic.LoadUint64(&d.statPacketsDispatched),
+		packetsDropped:    atomic.LoadUint64(&d.statPacketsDropped),
+	}
 }
 
-func (d *pktDispatcher) statOptions() []astikit.StatOptions {
-	return []astikit.StatOptions{
+func (d *pktDispatcher) statOptions() (ss []astikit.StatOptions) {
+	ss = []astikit.StatOptions{
 		{
 			Metadata: &astikit.StatMetadata{
 				Description: "Number of packets going out per second",
@@ -110,6 +406,18 @@ func (d *pktDispatcher) statOptions() []astikit.StatOptions {
 			Valuer: astikit.NewAtomicUint64RateStat(&d.statPacketsDispatched),
 		},
 	}
+	if d.drops() {
+		ss = append(ss, astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets dropped per second because a handler couldn't keep up",
+				Label:       "Dropped rate",
+				Name:        StatNameDroppedRate,
+				Unit:        "pps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&d.statPacketsDropped),
+		})
+	}
+	return
 }
 
 // PktCond represents an object that can decide whether to use a pkt
@@ -136,28 +444,50 @@ func (c *pktCond) Metadata() astiencoder.NodeMetadata {
 	return m
 }
 
+// HandleEOS implements the EOSHandler interface
+func (c *pktCond) HandleEOS(p EOSHandlerPayload) {
+	if v, ok := c.PktHandler.(EOSHandler); ok {
+		v.HandleEOS(p)
+	}
+}
+
 // UsePkt implements the PktCond interface
 func (c *pktCond) UsePkt(pkt *astiav.Packet) bool {
 	return pkt.StreamIndex() == c.i.Index
 }
 
+// Packets are only allocated when the pool is empty, therefore the number of allocated packets is
+// the maximum number of packets used at once
 type pktPool struct {
 	c                    astiencoder.Closer
 	m                    *sync.Mutex
 	p                    []*astiav.Packet
+	sem                  chan struct{}
 	statPacketsAllocated uint64
+	statPacketsUsed      int64
 }
 
 func newPktPool(c astiencoder.Closer) *pktPool {
-	return &pktPool{
+	return newBoundedPktPool(c, 0)
+}
+
+// If max > 0, get() blocks once max packets are used until one is put back or the node is stopped
+func newBoundedPktPool(c astiencoder.Closer, max int) (p *pktPool) {
+	p = &pktPool{
 		c: c,
 		m: &sync.Mutex{},
 	}
+	if max > 0 {
+		p.sem = make(chan struct{}, max)
+	}
+	return
 }
 
 func (p *pktPool) get() (pkt *astiav.Packet) {
+	poolAcquire(p.c, p.sem)
 	p.m.Lock()
 	defer p.m.Unlock()
+	atomic.AddInt64(&p.statPacketsUsed, 1)
 	if len(p.p) == 0 {
 		pkt = astiav.AllocPacket()
 		atomic.AddUint64(&p.statPacketsAllocated, 1)
@@ -169,19 +499,37 @@ func (p *pktPool) get() (pkt *astiav.Packet) {
 	return
 }
 
+// prewarm allocates n packets upfront so that the first get() calls don't pay the allocation cost
+func (p *pktPool) prewarm(n int) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for i := 0; i < n; i++ {
+		pkt := astiav.AllocPacket()
+		atomic.AddUint64(&p.statPacketsAllocated, 1)
+		p.c.AddClose(pkt.Free)
+		p.p = append(p.p, pkt)
+	}
+}
+
 func (p *pktPool) put(pkt *astiav.Packet) {
 	p.m.Lock()
 	defer p.m.Unlock()
 	pkt.Unref()
 	p.p = append(p.p, pkt)
+	atomic.AddInt64(&p.statPacketsUsed, -1)
+	poolRelease(p.sem)
 }
 
 type pktPoolStats struct {
 	packetsAllocated uint64
+	packetsUsed      int64
 }
 
 func (p *pktPool) stats() pktPoolStats {
-	return pktPoolStats{packetsAllocated: atomic.LoadUint64(&p.statPacketsAllocated)}
+	return pktPoolStats{
+		packetsAllocated: atomic.LoadUint64(&p.statPacketsAllocated),
+		packetsUsed:      atomic.LoadInt64(&p.statPacketsUsed),
+	}
 }
 
 func (p *pktPool) statOptions() []astikit.StatOptions {
@@ -195,5 +543,14 @@ func (p *pktPool) statOptions() []astikit.StatOptions {
 			},
 			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return atomic.LoadUint64(&p.statPacketsAllocated) }),
 		},
+		{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets taken from the pool and not put back yet",
+				Label:       "Used packets",
+				Name:        StatNameUsedPackets,
+				Unit:        "p",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return atomic.LoadInt64(&p.statPacketsUsed) }),
+		},
 	}
 }
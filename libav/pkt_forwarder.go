@@ -0,0 +1,206 @@
+package astilibav
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+var countPktForwarder uint64
+
+// PktForwarder represents an object capable of forwarding packets
+type PktForwarder struct {
+	*astiencoder.BaseNode
+	c                    *astikit.Chan
+	d                    *pktDispatcher
+	eh                   *astiencoder.EventHandler
+	eos                  *eosTracker
+	outputCtx            Context
+	p                    *pktPool
+	restamper            PktRestamper
+	statPacketsProcessed uint64
+	statPacketsReceived  uint64
+}
+
+// PktForwarderOptions represents pkt forwarder options
+type PktForwarderOptions struct {
+	Node      astiencoder.NodeOptions
+	OutputCtx Context
+	Restamper PktRestamper
+}
+
+// NewPktForwarder creates a new pkt forwarder
+func NewPktForwarder(o PktForwarderOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (f *PktForwarder) {
+	// Extend node metadata
+	count := atomic.AddUint64(&countPktForwarder, uint64(1))
+	o.Node.Metadata = o.Node.Metadata.Extend(fmt.Sprintf("pkt_forwarder_%d", count), fmt.Sprintf("Pkt Forwarder #%d", count), "Forwards packets", "pkt forwarder")
+
+	// Create pkt forwarder
+	f = &PktForwarder{
+		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh:        eh,
+		outputCtx: o.OutputCtx,
+		restamper: o.Restamper,
+	}
+
+	// Create base node
+	f.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, f, astiencoder.EventTypeToNodeEventName)
+
+	// Create end of stream tracker
+	f.eos = newEOSTracker(f)
+
+	// Create pkt pool
+	f.p = newPktPool(f)
+
+	// Create pkt dispatcher
+	f.d = newPktDispatcher(f, eh)
+
+	// Add stat options
+	f.addStatOptions()
+	return
+}
+
+type PktForwarderStats struct {
+	PacketsAllocated  uint64
+	PacketsDispatched uint64
+	PacketsProcessed  uint64
+	PacketsReceived   uint64
+	WorkDuration      time.Duration
+}
+
+func (f *PktForwarder) Stats() PktForwarderStats {
+	return PktForwarderStats{
+		PacketsAllocated:  f.p.stats().packetsAllocated,
+		PacketsDispatched: f.d.stats().packetsDispatched,
+		PacketsProcessed:  atomic.LoadUint64(&f.statPacketsProcessed),
+		PacketsReceived:   atomic.LoadUint64(&f.statPacketsReceived),
+		WorkDuration:      f.c.Stats().WorkDuration,
+	}
+}
+
+func (f *PktForwarder) addStatOptions() {
+	// Get stats
+	ss := f.c.StatOptions()
+	ss = append(ss, f.d.statOptions()...)
+	ss = append(ss, f.p.statOptions()...)
+	ss = append(ss,
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets coming in per second",
+				Label:       "Incoming rate",
+				Name:        StatNameIncomingRate,
+				Unit:        "pps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&f.statPacketsReceived),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of packets processed per second",
+				Label:       "Processed rate",
+				Name:        StatNameProcessedRate,
+				Unit:        "pps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&f.statPacketsProcessed),
+		},
+	)
+
+	// Add stats
+	f.BaseNode.AddStats(ss...)
+}
+
+// OutputCtx returns the output ctx
+func (f *PktForwarder) OutputCtx() Context {
+	return f.outputCtx
+}
+
+// Connect implements the PktHandlerConnector interface
+func (f *PktForwarder) Connect(h PktHandler) {
+	// Add handler
+	f.d.addHandler(h)
+
+	// Connect nodes
+	astiencoder.ConnectNodes(f, h)
+}
+
+// Disconnect implements the PktHandlerConnector interface
+func (f *PktForwarder) Disconnect(h PktHandler) {
+	// Delete handler
+	f.d.delHandler(h)
+
+	// Disconnect nodes
+	astiencoder.DisconnectNodes(f, h)
+}
+
+// Start starts the pkt forwarder
+func (f *PktForwarder) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
+	f.BaseNode.Start(ctx, t, func(t *astikit.Task) {
+		// Make sure to stop the chan properly
+		defer f.c.Stop()
+
+		// Start chan
+		f.c.Start(f.Context())
+	})
+}
+
+// HandleEOS implements the EOSHandler interface
+func (f *PktForwarder) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !f.eos.add(p.Node) {
+					return
+				}
+
+				// Dispatch end of stream
+				f.d.dispatchEOS()
+			})
+		})
+	})
+}
+
+// HandlePkt implements the PktHandler interface
+func (f *PktForwarder) HandlePkt(p PktHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	f.DoWhenUnclosed(func() {
+		// Increment received packets
+		atomic.AddUint64(&f.statPacketsReceived, 1)
+
+		// Copy pkt
+		pkt := f.p.get()
+		if err := pkt.Ref(p.Pkt); err != nil {
+			emitError(f, f.eh, err, "refing packet")
+			return
+		}
+
+		// Add to chan
+		f.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			f.DoWhenUnclosed(func() {
+				// Handle pause
+				defer f.HandlePause()
+
+				// Make sure to close pkt
+				defer f.p.put(pkt)
+
+				// Increment processed packets
+				atomic.AddUint64(&f.statPacketsProcessed, 1)
+
+				// Restamp
+				if f.restamper != nil {
+					f.restamper.Restamp(pkt)
+				}
+
+				// Dispatch pkt
+				f.d.dispatch(pkt, p.Descriptor)
+			})
+		})
+	})
+}
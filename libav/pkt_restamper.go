@@ -73,6 +73,73 @@ func (r *pktRestamperStartFromZero) Restamp(pkt *astiav.Packet) {
 	})
 }
 
+type pktRestamperWithFixedOffset struct {
+	offset int64
+}
+
+// NewPktRestamperWithOffset creates a new pkt restamper that adds a fixed offset to timestamps
+// offset must be a duration in pkt time base
+func NewPktRestamperWithOffset(offset int64) PktRestamper {
+	return &pktRestamperWithFixedOffset{offset: offset}
+}
+
+// Restamp implements the Restamper interface
+func (r *pktRestamperWithFixedOffset) Restamp(pkt *astiav.Packet) {
+	if pkt.Dts() != astiav.NoPtsValue {
+		pkt.SetDts(pkt.Dts() + r.offset)
+	}
+	if pkt.Pts() != astiav.NoPtsValue {
+		pkt.SetPts(pkt.Pts() + r.offset)
+	}
+}
+
+// MonotonicPktRestamper represents a pkt restamper that makes sure DTS are strictly increasing
+// per stream
+type MonotonicPktRestamper struct {
+	lastDTSs map[int]int64
+	m        *sync.Mutex
+}
+
+// NewMonotonicPktRestamper creates a new pkt restamper that bumps DTS lower than or equal to
+// the last DTS of the same stream to the last DTS + 1. PTS is shifted by the same delta
+func NewMonotonicPktRestamper() *MonotonicPktRestamper {
+	return &MonotonicPktRestamper{
+		lastDTSs: make(map[int]int64),
+		m:        &sync.Mutex{},
+	}
+}
+
+// Restamp implements the Restamper interface
+func (r *MonotonicPktRestamper) Restamp(pkt *astiav.Packet) {
+	// No dts
+	if pkt.Dts() == astiav.NoPtsValue {
+		return
+	}
+
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Bump timestamps
+	if lastDTS, ok := r.lastDTSs[pkt.StreamIndex()]; ok && pkt.Dts() <= lastDTS {
+		delta := lastDTS + 1 - pkt.Dts()
+		pkt.SetDts(pkt.Dts() + delta)
+		if pkt.Pts() != astiav.NoPtsValue {
+			pkt.SetPts(pkt.Pts() + delta)
+		}
+	}
+
+	// Store last dts
+	r.lastDTSs[pkt.StreamIndex()] = pkt.Dts()
+}
+
+// Reset forgets last DTS. It's useful after an intentional seek
+func (r *MonotonicPktRestamper) Reset() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.lastDTSs = make(map[int]int64)
+}
+
 type pktRestamperWithTime struct {
 	fillGaps      bool
 	firstAt       *time.Time
@@ -80,3 +80,42 @@ func TestPktRestamperWithTime(t *testing.T) {
 		require.Equal(t, v.outputDts, pkt.Pts())
 	}
 }
+
+func TestPktRestamperWithOffset(t *testing.T) {
+	pkt := astiav.AllocPacket()
+	require.NotNil(t, pkt)
+	defer pkt.Free()
+	r := NewPktRestamperWithOffset(100)
+	for _, ft := range []pktTest{
+		{inputDts: astiav.NoPtsValue, inputPts: astiav.NoPtsValue, outputDts: astiav.NoPtsValue, outputPts: astiav.NoPtsValue},
+		{inputDts: 0, inputPts: 2, outputDts: 100, outputPts: 102},
+		{inputDts: 10, inputPts: 13, outputDts: 110, outputPts: 113},
+	} {
+		pkt.SetDts(ft.inputDts)
+		pkt.SetPts(ft.inputPts)
+		r.Restamp(pkt)
+		require.Equal(t, ft.outputDts, pkt.Dts())
+		require.Equal(t, ft.outputPts, pkt.Pts())
+	}
+}
+
+func TestMonotonicPktRestamper(t *testing.T) {
+	pkt := astiav.AllocPacket()
+	require.NotNil(t, pkt)
+	defer pkt.Free()
+	r := NewMonotonicPktRestamper()
+	for _, ft := range []pktTest{
+		{inputDts: 10, inputPts: 12, outputDts: 10, outputPts: 12, streamIdx: 1},
+		{inputDts: 5, inputPts: 5, outputDts: 5, outputPts: 5, streamIdx: 2},
+		{inputDts: 10, inputPts: 11, outputDts: 11, outputPts: 12, streamIdx: 1},
+		{inputDts: 8, inputPts: 8, outputDts: 12, outputPts: 12, streamIdx: 1},
+		{inputDts: 6, inputPts: 6, outputDts: 6, outputPts: 6, streamIdx: 2},
+	} {
+		pkt.SetDts(ft.inputDts)
+		pkt.SetPts(ft.inputPts)
+		pkt.SetStreamIndex(ft.streamIdx)
+		r.Restamp(pkt)
+		require.Equal(t, ft.outputDts, pkt.Dts())
+		require.Equal(t, ft.outputPts, pkt.Pts())
+	}
+}
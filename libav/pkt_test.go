@@ -0,0 +1,147 @@
+package astilibav
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+	"github.com/stretchr/testify/require"
+)
+
+type mockedPktHandler struct {
+	*astiencoder.BaseNode
+	fn func(p PktHandlerPayload)
+}
+
+func newMockedPktHandler(name string, c *astikit.Closer, eh *astiencoder.EventHandler, fn func(p PktHandlerPayload)) (h *mockedPktHandler) {
+	h = &mockedPktHandler{fn: fn}
+	h.BaseNode = astiencoder.NewBaseNode(astiencoder.NodeOptions{Metadata: astiencoder.NodeMetadata{Name: name}}, c, eh, nil, h, astiencoder.EventTypeToNodeEventName)
+	return
+}
+
+func (h *mockedPktHandler) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {}
+
+func (h *mockedPktHandler) HandlePkt(p PktHandlerPayload) { h.fn(p) }
+
+func TestPktDispatcherConnectUnderLoad(t *testing.T) {
+	for _, s := range []PktDispatchStrategy{PktDispatchStrategyBlock, PktDispatchStrategyDropNewest, PktDispatchStrategyDropOldest} {
+		t.Run(string(s), func(t *testing.T) {
+			// Create dispatcher
+			eh := astiencoder.NewEventHandler()
+			c := astikit.NewCloser()
+			defer c.Close()
+			d := newPktDispatcherWithOptions(newMockedPktHandler("source", c, eh, func(p PktHandlerPayload) {}), eh, PktDispatcherOptions{
+				BufferSize: 10,
+				Strategy:   s,
+			})
+
+			// Create handlers
+			var count uint64
+			var hs []*mockedPktHandler
+			for i := 0; i < 10; i++ {
+				hs = append(hs, newMockedPktHandler(fmt.Sprintf("mocked_%d", i), c, eh, func(p PktHandlerPayload) {
+					atomic.AddUint64(&count, 1)
+				}))
+			}
+
+			// Dispatch packets
+			pkt := astiav.AllocPacket()
+			defer pkt.Free()
+			stop := make(chan struct{})
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						d.dispatch(pkt, NewDescriptor(astiav.NewRational(1, 25)))
+					}
+				}
+			}()
+
+			// Connect and disconnect handlers while dispatching
+			for i := 0; i < 100; i++ {
+				for _, h := range hs {
+					d.addHandler(h)
+				}
+				for _, h := range hs {
+					d.delHandler(h)
+				}
+			}
+
+			// Connected handler should receive packets
+			d.addHandler(hs[0])
+			before := atomic.LoadUint64(&count)
+			require.Eventually(t, func() bool { return atomic.LoadUint64(&count) > before }, time.Second, time.Millisecond)
+			close(stop)
+			wg.Wait()
+
+			// Buffered packets should all be put back once queues are stopped
+			d.close()
+			if d.p != nil {
+				require.Eventually(t, func() bool { return d.p.stats().packetsUsed == 0 }, time.Second, time.Millisecond)
+			}
+		})
+	}
+}
+
+type mockedEOSPktHandler struct {
+	*mockedPktHandler
+	fn func(p EOSHandlerPayload)
+}
+
+func (h *mockedEOSPktHandler) HandleEOS(p EOSHandlerPayload) { h.fn(p) }
+
+func TestPktDispatcherEOS(t *testing.T) {
+	for _, s := range []PktDispatchStrategy{PktDispatchStrategyBlock, PktDispatchStrategyDropNewest} {
+		t.Run(string(s), func(t *testing.T) {
+			// Create dispatcher
+			eh := astiencoder.NewEventHandler()
+			c := astikit.NewCloser()
+			defer c.Close()
+			src := newMockedPktHandler("source", c, eh, func(p PktHandlerPayload) {})
+			d := newPktDispatcherWithOptions(src, eh, PktDispatcherOptions{Strategy: s})
+
+			// Connect handler
+			m := &sync.Mutex{}
+			var ss []string
+			d.addHandler(&mockedEOSPktHandler{
+				fn: func(p EOSHandlerPayload) {
+					m.Lock()
+					defer m.Unlock()
+					ss = append(ss, "eos:"+p.Node.Metadata().Name)
+				},
+				mockedPktHandler: newMockedPktHandler("mocked", c, eh, func(p PktHandlerPayload) {
+					m.Lock()
+					defer m.Unlock()
+					ss = append(ss, "pkt")
+				}),
+			})
+
+			// Dispatch
+			pkt := astiav.AllocPacket()
+			defer pkt.Free()
+			for i := 0; i < 3; i++ {
+				d.dispatch(pkt, NewDescriptor(astiav.NewRational(1, 25)))
+			}
+			d.dispatchEOS()
+
+			// End of stream should be handled after pkts
+			require.Eventually(t, func() bool {
+				m.Lock()
+				defer m.Unlock()
+				return len(ss) == 4
+			}, time.Second, time.Millisecond)
+			require.Equal(t, []string{"pkt", "pkt", "pkt", "eos:source"}, ss)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package astilibav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astikit"
+)
+
+// ProbeOptions represents probe options
+type ProbeOptions struct {
+	// If > 0, caps the duration of data analyzed when finding stream info. Along with ProbeSize, it
+	// bounds the amount of data read before opening is done, which gives a deterministic upper bound
+	// on probing latency even when ffmpeg doesn't check the interrupt callback often
+	AnalyzeDuration time.Duration
+	// String content of the demuxer as you would use in ffmpeg
+	Dictionary *Dictionary
+	// String content of the options used when finding stream info (e.g. analyzeduration
+	// or probesize) as you would use in ffmpeg
+	FindStreamInfoOptions *Dictionary
+	// Exact input format
+	Format *astiav.InputFormat
+	// If > 0, caps the number of bytes read to detect the input format and find stream info.
+	// Too small values may prevent codec parameters from being found
+	ProbeSize int64
+	// If provided, the input is read from the reader instead of URL.
+	// If the reader doesn't implement io.Seeker, the input is considered as non-seekable.
+	Reader io.Reader
+	// URL of the input
+	URL string
+}
+
+// ProbeResult represents a probe result
+type ProbeResult struct {
+	Metadata DemuxerMetadata
+	// Since everything is closed once probing is done, CodecParameters is always nil
+	Streams []*Stream
+}
+
+// Probe opens the input, finds its stream info and closes everything without creating a node.
+// Cancelling the context interrupts probing
+func Probe(ctx context.Context, o ProbeOptions) (r ProbeResult, err error) {
+	// Create closer
+	c := astikit.NewCloser()
+
+	// Make sure everything is closed
+	defer c.Close()
+
+	// Open format context
+	var fc *astiav.FormatContext
+	if fc, _, err = openFormatContext(ctx, o, c); err != nil {
+		err = fmt.Errorf("astilibav: opening format context failed: %w", err)
+		return
+	}
+
+	// Store metadata
+	r.Metadata = newDemuxerMetadata(fc)
+
+	// Loop through streams
+	for _, s := range fc.Streams() {
+		// Create stream
+		st := newStream(s, NewContextFromStream(s))
+
+		// Codec parameters are freed once probing is done
+		st.CodecParameters = nil
+
+		// Append stream
+		r.Streams = append(r.Streams, st)
+	}
+	return
+}
+
+func openFormatContext(probeCtx context.Context, o ProbeOptions, c *astikit.Closer) (fc *astiav.FormatContext, interruptRet *int, err error) {
+	// Dictionary
+	var dict *astiav.Dictionary
+	if o.Dictionary != nil {
+		// Parse dict
+		if dict, err = o.Dictionary.parse(); err != nil {
+			err = fmt.Errorf("astilibav: parsing dict failed: %w", err)
+			return
+		}
+
+		// Make sure the dictionary is freed
+		defer dict.Free()
+	}
+
+	// Probe caps are format context options, therefore they're provided when opening the input
+	if o.AnalyzeDuration > 0 || o.ProbeSize > 0 {
+		// Create dict
+		if dict == nil {
+			dict = astiav.NewDictionary()
+			defer dict.Free()
+		}
+
+		// Analyze duration is in microseconds
+		if o.AnalyzeDuration > 0 {
+			if err = dict.Set("analyzeduration", strconv.FormatInt(o.AnalyzeDuration.Microseconds(), 10), 0); err != nil {
+				err = fmt.Errorf("astilibav: setting analyzeduration failed: %w", err)
+				return
+			}
+		}
+
+		// Probe size
+		if o.ProbeSize > 0 {
+			if err = dict.Set("probesize", strconv.FormatInt(o.ProbeSize, 10), 0); err != nil {
+				err = fmt.Errorf("astilibav: setting probesize failed: %w", err)
+				return
+			}
+		}
+	}
+
+	// Alloc format context
+	fc = astiav.AllocFormatContext()
+
+	// Make sure the format context is properly freed
+	c.Add(fc.Free)
+
+	// Set interrupt callback
+	interruptRet = fc.SetInterruptCallback()
+
+	// Handle probe cancellation
+	if probeCtx != nil {
+		// Create context
+		ctx, cancel := context.WithCancel(probeCtx)
+
+		// Handle interrupt
+		*interruptRet = 0
+		go func() {
+			<-ctx.Done()
+			if probeCtx.Err() != nil {
+				*interruptRet = 1
+			}
+		}()
+
+		// Make sure to cancel context so that go routine is closed
+		defer cancel()
+	}
+
+	// Handle reader
+	if o.Reader != nil {
+		// Alloc io context
+		var ioContext *astiav.IOContext
+		if ioContext, err = astiav.AllocIOContext(ioContextBufferSize, o.Reader.Read, newIOContextSeekFunc(o.Reader), nil); err != nil {
+			err = fmt.Errorf("astilibav: allocating io context failed: %w", err)
+			return
+		}
+
+		// Make sure the io context is properly freed
+		c.Add(ioContext.Free)
+
+		// Set pb
+		fc.SetPb(ioContext)
+	}
+
+	// Open input
+	if err = fc.OpenInput(o.URL, o.Format, dict); err != nil {
+		err = fmt.Errorf("astilibav: opening input failed: %w", err)
+		return
+	}
+
+	// Make sure the input is properly closed
+	c.Add(fc.CloseInput)
+
+	// Check whether probe has been cancelled
+	if probeCtx != nil && probeCtx.Err() != nil {
+		err = fmt.Errorf("astilibav: probing has been cancelled: %w", probeCtx.Err())
+		return
+	}
+
+	// Find stream info dictionary
+	var findStreamInfoDict *astiav.Dictionary
+	if o.FindStreamInfoOptions != nil {
+		// Parse dict
+		if findStreamInfoDict, err = o.FindStreamInfoOptions.parse(); err != nil {
+			err = fmt.Errorf("astilibav: parsing find stream info dict failed: %w", err)
+			return
+		}
+
+		// Make sure the dictionary is freed
+		defer findStreamInfoDict.Free()
+	}
+
+	// Find stream information
+	if err = fc.FindStreamInfo(findStreamInfoDict); err != nil {
+		err = fmt.Errorf("astilibav: finding stream info failed: %w", err)
+		return
+	}
+
+	// Check whether probe has been cancelled
+	if probeCtx != nil && probeCtx.Err() != nil {
+		err = fmt.Errorf("astilibav: probing has been cancelled: %w", probeCtx.Err())
+		return
+	}
+	return
+}
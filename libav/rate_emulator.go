@@ -10,24 +10,37 @@ type rateEmulatorAtFunc func(i interface{}) time.Time
 
 type rateEmulatorBeforeFunc func(a, b interface{}) bool
 
-type rateEmulatorExecFunc func(i interface{})
-
+// at is the time at which the item was supposed to be executed
+type rateEmulatorExecFunc func(i interface{}, at time.Time)
+
+// Items are sorted using funcBefore when added, therefore items are executed in order as long as
+// they're added before the previous item is executed. Otherwise, an item added after one that
+// should have been executed later is executed as soon as possible, which is out of order.
+//
+// If window > 0, the first item is executed only once more than window items have been added, so
+// that items reordered by at most window positions (e.g. B-frames) are executed in order no matter
+// when they're added. The last window items are therefore held until more items are added or
+// until the emulator is flushed
 type rateEmulator struct {
 	buffer      []interface{}
 	cancel      context.CancelFunc
+	clock       Clock
 	ctx         context.Context
 	flushOnStop bool
+	flushing    bool
 	funcAt      rateEmulatorAtFunc
 	funcBefore  rateEmulatorBeforeFunc
 	funcExec    rateEmulatorExecFunc
 	items       []interface{}
 	m           *sync.Mutex
 	nextAt      time.Time
-	reloadChan  chan bool
+	reload      context.CancelFunc
+	window      int
 }
 
-func newRateEmulator(flushOnStop bool, funcAt rateEmulatorAtFunc, funcBefore rateEmulatorBeforeFunc, funcExec rateEmulatorExecFunc) *rateEmulator {
+func newRateEmulator(clock Clock, flushOnStop bool, funcAt rateEmulatorAtFunc, funcBefore rateEmulatorBeforeFunc, funcExec rateEmulatorExecFunc) *rateEmulator {
 	return &rateEmulator{
+		clock:       clockOrDefault(clock),
 		flushOnStop: flushOnStop,
 		funcAt:      funcAt,
 		funcBefore:  funcBefore,
@@ -76,37 +89,32 @@ func (r *rateEmulator) startLoopCycle(ctx context.Context) (stop bool) {
 	r.m.Lock()
 	nextAt := r.nextAt
 
-	// Create reload chan
-	r.reloadChan = make(chan bool)
+	// Create reload context
+	reloadCtx, reload := context.WithCancel(ctx)
+	r.reload = reload
 	r.m.Unlock()
 
-	// Make sure to close reload chan
+	// Make sure to cancel reload context
 	defer func() {
 		// Lock
 		r.m.Lock()
 		defer r.m.Unlock()
 
-		// Close
-		if r.reloadChan != nil {
-			close(r.reloadChan)
-			r.reloadChan = nil
-		}
+		// Cancel
+		reload()
+		r.reload = nil
 	}()
 
 	// No next at
 	if nextAt.IsZero() {
-		// Select
-		select {
-		case <-ctx.Done():
-			stop = true
-			return
-		case <-r.reloadChan:
-			return
-		}
+		// Wait
+		<-reloadCtx.Done()
+		stop = ctx.Err() != nil
+		return
 	}
 
 	// Get duration
-	d := time.Until(nextAt)
+	d := nextAt.Sub(r.clock.Now())
 
 	// Run immediatly
 	if d <= 0 {
@@ -114,20 +122,22 @@ func (r *rateEmulator) startLoopCycle(ctx context.Context) (stop bool) {
 		return
 	}
 
-	// Create timer
-	t := time.NewTimer(d)
-	defer t.Stop()
+	// Sleep
+	r.clock.Sleep(reloadCtx, d) //nolint:errcheck
 
-	// Select
-	select {
-	case <-ctx.Done():
+	// Context is done
+	if ctx.Err() != nil {
 		stop = true
 		return
-	case <-r.reloadChan:
+	}
+
+	// Next at has changed
+	if reloadCtx.Err() != nil {
 		return
-	case <-t.C:
-		r.run()
 	}
+
+	// Run
+	r.run()
 	return
 }
 
@@ -137,7 +147,16 @@ func (r *rateEmulator) setFlushOnStop(flushOnStop bool) {
 	r.flushOnStop = flushOnStop
 }
 
+func (r *rateEmulator) setWindow(window int) {
+	r.update(func() { r.window = window })
+}
+
 func (r *rateEmulator) flush() {
+	// Remaining items shouldn't be held anymore
+	r.update(func() { r.flushing = true })
+	defer r.update(func() { r.flushing = false })
+
+	// Loop
 	for {
 		if stop := r.flushLoopCycle(); stop {
 			break
@@ -158,7 +177,7 @@ func (r *rateEmulator) flushLoopCycle() (stop bool) {
 	}
 
 	// Sleep
-	time.Sleep(time.Until(nextAt))
+	r.clock.Sleep(context.Background(), nextAt.Sub(r.clock.Now())) //nolint:errcheck
 
 	// Run
 	r.run()
@@ -173,17 +192,17 @@ func (r *rateEmulator) run() {
 		return
 	}
 	i := r.items[0]
+	at := r.funcAt(i)
 	if len(r.items) > 1 {
 		r.items = r.items[1:]
-		r.nextAt = r.funcAt(r.items[0])
 	} else {
 		r.items = []interface{}{}
-		r.nextAt = time.Time{}
 	}
+	r.nextAt = r.nextAtUnlocked()
 	r.m.Unlock()
 
 	// Exec
-	r.funcExec(i)
+	r.funcExec(i, at)
 }
 
 func (r *rateEmulator) stop() {
@@ -229,8 +248,35 @@ func (r *rateEmulator) addUnlocked(i interface{}) {
 		r.items = append(r.items, i)
 	}
 
+	// Refresh next at
+	r.refreshNextAtUnlocked()
+}
+
+// update executes fn while no item can be processed and refreshes next at afterwards.
+// It must be used when fn modifies what funcAt returns
+func (r *rateEmulator) update(fn func()) {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Execute
+	fn()
+
+	// Refresh next at
+	r.refreshNextAtUnlocked()
+}
+
+func (r *rateEmulator) nextAtUnlocked() (nextAt time.Time) {
+	// No items or first item is held
+	if len(r.items) == 0 || (!r.flushing && len(r.items) <= r.window) {
+		return
+	}
+	return r.funcAt(r.items[0])
+}
+
+func (r *rateEmulator) refreshNextAtUnlocked() {
 	// Get next at
-	nextAt := r.funcAt(r.items[0])
+	nextAt := r.nextAtUnlocked()
 
 	// Next at hasn't change
 	if r.nextAt.Equal(nextAt) {
@@ -241,8 +287,8 @@ func (r *rateEmulator) addUnlocked(i interface{}) {
 	r.nextAt = nextAt
 
 	// Reload
-	if r.reloadChan != nil {
-		close(r.reloadChan)
-		r.reloadChan = nil
+	if r.reload != nil {
+		r.reload()
+		r.reload = nil
 	}
 }
@@ -0,0 +1,131 @@
+package astilibav
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockedClock struct {
+	m   *sync.Mutex
+	now time.Time
+}
+
+func newMockedClock(now time.Time) *mockedClock {
+	return &mockedClock{
+		m:   &sync.Mutex{},
+		now: now,
+	}
+}
+
+func (c *mockedClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+// Sleep advances time instantly
+func (c *mockedClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.now = c.now.Add(d)
+	return nil
+}
+
+func TestRateEmulatorClock(t *testing.T) {
+	// Create rate emulator
+	t0 := time.Unix(1000, 0)
+	c := newMockedClock(t0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ats, nows []time.Time
+	r := newRateEmulator(c, false, func(i interface{}) time.Time {
+		return t0.Add(i.(time.Duration))
+	}, func(a, b interface{}) bool {
+		return a.(time.Duration) < b.(time.Duration)
+	}, func(i interface{}, at time.Time) {
+		ats = append(ats, at)
+		nows = append(nows, c.Now())
+		if len(ats) == 3 {
+			cancel()
+		}
+	})
+
+	// Items added before start are buffered
+	r.add(2 * time.Second)
+	r.add(time.Second)
+	r.add(3 * time.Second)
+
+	// Start
+	r.start(ctx)
+
+	// Items are executed in order once the clock has reached their time
+	require.Equal(t, []time.Time{t0.Add(time.Second), t0.Add(2 * time.Second), t0.Add(3 * time.Second)}, ats)
+	require.Equal(t, ats, nows)
+}
+
+func TestRateEmulatorReorderWindow(t *testing.T) {
+	for _, v := range []struct {
+		expected []time.Duration
+		window   int
+	}{
+		{
+			// Items added after a later item has been executed are executed out of order
+			expected: []time.Duration{time.Second, 3 * time.Second, 2 * time.Second, 5 * time.Second, 4 * time.Second, 6 * time.Second},
+		},
+		{
+			expected: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second, 5 * time.Second, 6 * time.Second},
+			window:   1,
+		},
+	} {
+		// Create rate emulator
+		t0 := time.Unix(1000, 0)
+		c := newMockedClock(t0)
+		ctx, cancel := context.WithCancel(context.Background())
+		m := &sync.Mutex{}
+		var is []time.Duration
+		r := newRateEmulator(c, true, func(i interface{}) time.Time {
+			return t0.Add(i.(time.Duration))
+		}, func(a, b interface{}) bool {
+			return a.(time.Duration) < b.(time.Duration)
+		}, func(i interface{}, at time.Time) {
+			m.Lock()
+			defer m.Unlock()
+			is = append(is, i.(time.Duration))
+		})
+		r.setWindow(v.window)
+
+		// Start
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.start(ctx)
+		}()
+
+		// Items are added in decode order, each one once the previous one can't be held anymore
+		for idx, d := range []time.Duration{time.Second, 3 * time.Second, 2 * time.Second, 5 * time.Second, 4 * time.Second, 6 * time.Second} {
+			require.Eventually(t, func() bool {
+				r.m.Lock()
+				defer r.m.Unlock()
+				return r.ctx != nil
+			}, time.Second, time.Millisecond)
+			r.add(d)
+			require.Eventually(t, func() bool {
+				m.Lock()
+				defer m.Unlock()
+				return len(is) >= idx+1-v.window
+			}, time.Second, time.Millisecond)
+		}
+
+		// Stop and flush held items
+		cancel()
+		<-done
+		require.Equal(t, v.expected, is)
+	}
+}
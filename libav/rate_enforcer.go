@@ -2,7 +2,12 @@ package astilibav
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"image"
+	"math"
+	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,28 +19,70 @@ import (
 
 var countRateEnforcer uint64
 
+// Number of ticks used to compute the slot occupancy
+const rateEnforcerOccupancyWindow = 100
+
+// Number of frames used to compute the delay percentiles
+const rateEnforcerDelayWindow = 1000
+
 // RateEnforcer represents an object capable of enforcing rate based on PTS
 type RateEnforcer struct {
 	*astiencoder.BaseNode
+	adaptSlots          bool
 	c                   *astikit.Chan
 	currentNode         astiencoder.Node
 	d                   *frameDispatcher
 	delay               time.Duration
+	delays              []time.Duration
+	delaysIdx           int
 	descriptor          Descriptor
 	desiredNode         astiencoder.Node
 	eh                  *astiencoder.EventHandler
 	f                   RateEnforcerFiller
 	frames              map[astiencoder.Node][]*astiav.Frame
+	intervals           map[astiencoder.Node]*rateEnforcerInterval
 	m                   *sync.Mutex
+	occupancy           []bool
+	occupancyIdx        int
 	outputCtx           Context
 	p                   *framePool
-	period              time.Duration
+	period              int64
 	ptsReferences       map[astiencoder.Node]*rateEnforcerPTSReference
 	restamper           FrameRestamper
 	statFramesDelay     *astikit.AtomicDuration
 	statFramesFilled    uint64
 	statFramesProcessed uint64
 	statFramesReceived  uint64
+	transition          *rateEnforcerTransition
+}
+
+type rateEnforcerTransition struct {
+	d       time.Duration
+	n       astiencoder.Node
+	startAt time.Time
+}
+
+// Weight of the latest inter-frame interval in the observed interval moving average
+const rateEnforcerIntervalWeight = 8
+
+type rateEnforcerInterval struct {
+	avg           int64
+	dispatchedPTS int64
+	lastPTS       int64
+}
+
+func (i *rateEnforcerInterval) update(pts int64) {
+	// Frames are not always received in order
+	if d := pts - i.lastPTS; d > 0 {
+		if i.avg == 0 {
+			i.avg = d
+		} else {
+			i.avg += (d - i.avg) / rateEnforcerIntervalWeight
+		}
+	}
+	if pts > i.lastPTS {
+		i.lastPTS = pts
+	}
 }
 
 type rateEnforcerPTSReference struct {
@@ -62,12 +109,25 @@ func (r *rateEnforcerPTSReference) ptsFromTime(t time.Time) int64 {
 
 // RateEnforcerOptions represents rate enforcer options
 type RateEnforcerOptions struct {
-	Delay  time.Duration
+	// If true, the PTS span of each slot adapts to the observed inter-frame interval of incoming
+	// frames rather than only to the output framerate, and the most recent frame of the span is
+	// used. This helps converting variable frame rate content to constant frame rate
+	AdaptSlotsToIncomingFrames bool
+	Delay                      time.Duration
+	// Defaults to repeating the previous frame for video and to silence for audio.
+	// Use NewBlankRateEnforcerFiller to fill video gaps with black frames instead, or
+	// NewImageRateEnforcerFiller to fill them with a still image
 	Filler RateEnforcerFiller
 	Node   astiencoder.NodeOptions
-	// Both FrameRate and TimeBase are mandatory
+	// Both FrameRate and TimeBase are mandatory for video.
+	// For audio, SampleRate, FrameSize, TimeBase, ChannelLayout and SampleFormat are mandatory:
+	// the period is derived from the number of samples per frame and, if no filler is provided,
+	// silence frames are used to fill gaps
 	OutputCtx Context
-	Restamper FrameRestamper
+	// Number of frames allocated when the rate enforcer is created, so that latency-sensitive
+	// pipelines don't pay the allocation cost on the first frames
+	PrewarmedFrames int
+	Restamper       FrameRestamper
 }
 
 // NewRateEnforcer creates a new rate enforcer
@@ -78,15 +138,19 @@ func NewRateEnforcer(o RateEnforcerOptions, eh *astiencoder.EventHandler, c *ast
 
 	// Create rate enforcer
 	r = &RateEnforcer{
+		adaptSlots:      o.AdaptSlotsToIncomingFrames,
 		c:               astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
 		delay:           o.Delay,
+		delays:          make([]time.Duration, 0, rateEnforcerDelayWindow),
 		descriptor:      o.OutputCtx.Descriptor(),
 		frames:          make(map[astiencoder.Node][]*astiav.Frame),
+		intervals:       make(map[astiencoder.Node]*rateEnforcerInterval),
 		eh:              eh,
 		f:               o.Filler,
 		m:               &sync.Mutex{},
+		occupancy:       make([]bool, 0, rateEnforcerOccupancyWindow),
 		outputCtx:       o.OutputCtx,
-		period:          time.Duration(float64(1e9) / o.OutputCtx.FrameRate.ToDouble()),
+		period:          int64(rateEnforcerPeriod(o.OutputCtx)),
 		ptsReferences:   map[astiencoder.Node]*rateEnforcerPTSReference{},
 		restamper:       o.Restamper,
 		statFramesDelay: astikit.NewAtomicDuration(0),
@@ -97,10 +161,19 @@ func NewRateEnforcer(o RateEnforcerOptions, eh *astiencoder.EventHandler, c *ast
 
 	// Create frame pool
 	r.p = newFramePool(r)
+	r.p.prewarm(o.PrewarmedFrames)
 
 	// Create frame dispatcher
 	r.d = newFrameDispatcher(r, eh)
 
+	// Create silence filler
+	if r.f == nil && o.OutputCtx.MediaType == astiav.MediaTypeAudio {
+		var err error
+		if r.f, err = NewBlankRateEnforcerFiller(o.OutputCtx, c); err != nil {
+			emitError(r, r.eh, err, "creating blank filler")
+		}
+	}
+
 	// Create filler
 	if r.f == nil {
 		r.f = newPreviousRateEnforcerFiller(r, r.eh, r.p)
@@ -111,6 +184,14 @@ func NewRateEnforcer(o RateEnforcerOptions, eh *astiencoder.EventHandler, c *ast
 	return
 }
 
+func rateEnforcerPeriod(ctx Context) time.Duration {
+	// Audio period is based on the number of samples per frame
+	if ctx.MediaType == astiav.MediaTypeAudio && ctx.SampleRate > 0 && ctx.FrameSize > 0 {
+		return time.Duration(int64(ctx.FrameSize) * 1e9 / int64(ctx.SampleRate))
+	}
+	return time.Duration(float64(1e9) / ctx.FrameRate.ToDouble())
+}
+
 type RateEnforcerStats struct {
 	FramesAllocated uint64
 	FramesDelay     time.Duration
@@ -166,6 +247,33 @@ func (r *RateEnforcer) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicDurationAvgStat(r.statFramesDelay, &r.statFramesProcessed),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "50th percentile of the delay of the last frames coming in",
+				Label:       "Delay p50",
+				Name:        StatNameDelayP50,
+				Unit:        "ns",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return float64(r.delayPercentile(50)) }),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "95th percentile of the delay of the last frames coming in",
+				Label:       "Delay p95",
+				Name:        StatNameDelayP95,
+				Unit:        "ns",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return float64(r.delayPercentile(95)) }),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "99th percentile of the delay of the last frames coming in",
+				Label:       "Delay p99",
+				Name:        StatNameDelayP99,
+				Unit:        "ns",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return float64(r.delayPercentile(99)) }),
+		},
 		astikit.StatOptions{
 			Metadata: &astikit.StatMetadata{
 				Description: "Number of frames filled per second",
@@ -175,12 +283,109 @@ func (r *RateEnforcer) addStatOptions() {
 			},
 			Valuer: astikit.NewAtomicUint64RateStat(&r.statFramesFilled),
 		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Percentage of the last ticks where an actual frame was dispatched",
+				Label:       "Slot occupancy",
+				Name:        StatNameSlotOccupancy,
+				Unit:        "%",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return r.slotOccupancy() }),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames buffered",
+				Label:       "Buffered frames",
+				Name:        StatNameBufferedFrames,
+				Unit:        "f",
+			},
+			Valuer: astikit.StatValuerFunc(func(d time.Duration) interface{} { return r.bufferedFrames() }),
+		},
 	)
 
 	// Add stats
 	r.BaseNode.AddStats(ss...)
 }
 
+func (r *RateEnforcer) slotOccupancy() float64 {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// No ticks
+	if len(r.occupancy) == 0 {
+		return 0
+	}
+
+	// Count occupied slots
+	var c int
+	for _, v := range r.occupancy {
+		if v {
+			c++
+		}
+	}
+	return float64(c) / float64(len(r.occupancy)) * 100
+}
+
+func (r *RateEnforcer) updateSlotOccupancy(occupied bool) {
+	// Window is not full yet
+	if len(r.occupancy) < cap(r.occupancy) {
+		r.occupancy = append(r.occupancy, occupied)
+		return
+	}
+
+	// Replace oldest value
+	r.occupancy[r.occupancyIdx] = occupied
+	r.occupancyIdx = (r.occupancyIdx + 1) % len(r.occupancy)
+}
+
+func (r *RateEnforcer) delayPercentile(p float64) time.Duration {
+	// Copy delays
+	r.m.Lock()
+	ds := make([]time.Duration, len(r.delays))
+	copy(ds, r.delays)
+	r.m.Unlock()
+
+	// No delays
+	if len(ds) == 0 {
+		return 0
+	}
+
+	// Sort delays
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+
+	// Get nearest rank
+	idx := int(math.Ceil(p/100*float64(len(ds)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return ds[idx]
+}
+
+func (r *RateEnforcer) updateDelays(d time.Duration) {
+	// Window is not full yet
+	if len(r.delays) < cap(r.delays) {
+		r.delays = append(r.delays, d)
+		return
+	}
+
+	// Replace oldest value
+	r.delays[r.delaysIdx] = d
+	r.delaysIdx = (r.delaysIdx + 1) % len(r.delays)
+}
+
+func (r *RateEnforcer) bufferedFrames() (c int) {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Count frames
+	for _, fs := range r.frames {
+		c += len(fs)
+	}
+	return
+}
+
 // OutputCtx returns the output ctx
 func (r *RateEnforcer) OutputCtx() Context {
 	return r.outputCtx
@@ -191,6 +396,76 @@ func (r *RateEnforcer) Switch(n astiencoder.Node) {
 	r.m.Lock()
 	defer r.m.Unlock()
 	r.desiredNode = n
+	r.transition = nil
+}
+
+// SwitchWithTransition switches the source by blending frames of the current and new sources
+// over the provided duration. EventNameRateEnforcerSwitchedIn is emitted when the transition starts
+// and EventNameRateEnforcerSwitchedOut is emitted when it ends.
+// Blending is a simple alpha mix of raw bytes which is only meaningful for 8-bit pixel formats.
+// Audio frames, frames with different formats or a missing current source result in a hard cut
+func (r *RateEnforcer) SwitchWithTransition(n astiencoder.Node, d time.Duration) {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// No transition needed
+	if d <= 0 || r.currentNode == nil || r.currentNode == n || r.outputCtx.MediaType != astiav.MediaTypeVideo {
+		r.desiredNode = n
+		r.transition = nil
+		return
+	}
+
+	// Store transition
+	r.desiredNode = r.currentNode
+	r.transition = &rateEnforcerTransition{
+		d: d,
+		n: n,
+	}
+}
+
+// SetFrameRate updates the output framerate. It takes effect on the next tick.
+// Output ctx is not updated, therefore nodes connected to the rate enforcer are not notified
+func (r *RateEnforcer) SetFrameRate(fr astiav.Rational) {
+	// Invalid framerate
+	if fr.Num() <= 0 || fr.Den() <= 0 {
+		emitError(r, r.eh, fmt.Errorf("astilibav: invalid framerate %s", fr), "setting framerate")
+		return
+	}
+
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Update period
+	atomic.StoreInt64(&r.period, int64(time.Duration(float64(1e9)/fr.ToDouble())))
+}
+
+// Reset drops buffered frames and pts references so that stale frames don't leak through.
+// It's safe to call it while the rate enforcer is running
+func (r *RateEnforcer) Reset() {
+	// Lock
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// Drop buffered frames
+	for n, fs := range r.frames {
+		for _, f := range fs {
+			r.p.put(f)
+		}
+		delete(r.frames, n)
+	}
+
+	// Reset pts references
+	r.ptsReferences = map[astiencoder.Node]*rateEnforcerPTSReference{}
+
+	// Reset intervals
+	r.intervals = make(map[astiencoder.Node]*rateEnforcerInterval)
+
+	// Reset filler
+	if v, ok := r.f.(RateEnforcerFillerResetter); ok {
+		v.Reset()
+	}
 }
 
 // Connect implements the FrameHandlerConnector interface
@@ -249,6 +524,7 @@ func (r *RateEnforcer) HandleFrame(p FrameHandlerPayload) {
 		}
 
 		// Copy frame
+		// Side data is preserved
 		f := r.p.get()
 		if err := f.Ref(p.Frame); err != nil {
 			emitError(r, r.eh, err, "refing frame")
@@ -296,6 +572,19 @@ func (r *RateEnforcer) HandleFrame(p FrameHandlerPayload) {
 					r.frames[p.Node] = append(r.frames[p.Node], f)
 				}
 
+				// Update observed interval
+				if r.adaptSlots {
+					i, ok := r.intervals[p.Node]
+					if !ok {
+						i = &rateEnforcerInterval{
+							dispatchedPTS: astiav.NoPtsValue,
+							lastPTS:       f.Pts(),
+						}
+						r.intervals[p.Node] = i
+					}
+					i.update(f.Pts())
+				}
+
 				// Update pts reference
 				ptsReference, ok := r.ptsReferences[p.Node]
 				if !ok || ptsReference == nil || ptsReference.timeFromPTS(f.Pts()).After(t) {
@@ -303,9 +592,11 @@ func (r *RateEnforcer) HandleFrame(p FrameHandlerPayload) {
 					ptsReference = r.ptsReferences[p.Node]
 				}
 
-				// Increment frames delay
+				// Update frames delay
 				if r.currentNode == p.Node {
-					r.statFramesDelay.Add(t.Sub(ptsReference.timeFromPTS(f.Pts())))
+					d := t.Sub(ptsReference.timeFromPTS(f.Pts()))
+					r.statFramesDelay.Add(d)
+					r.updateDelays(d)
 				}
 			})
 		})
@@ -323,7 +614,7 @@ func (r *RateEnforcer) startTick(ctx context.Context) {
 
 func (r *RateEnforcer) tickFunc(ctx context.Context, nextAt *time.Time) (stop bool) {
 	// Compute next at
-	*nextAt = nextAt.Add(r.period)
+	*nextAt = nextAt.Add(time.Duration(atomic.LoadInt64(&r.period)))
 
 	// Sleep until next at
 	if delta := time.Until(*nextAt); delta > 0 {
@@ -342,6 +633,9 @@ func (r *RateEnforcer) tickFunc(ctx context.Context, nextAt *time.Time) (stop bo
 	// Get frame
 	f, n, filled := r.frame(*nextAt)
 
+	// Update slot occupancy
+	r.updateSlotOccupancy(!filled)
+
 	// Process frame
 	if f != nil {
 		// Restamp frame
@@ -380,7 +674,16 @@ func (r *RateEnforcer) tickFunc(ctx context.Context, nextAt *time.Time) (stop bo
 
 func (r *RateEnforcer) frame(from time.Time) (f *astiav.Frame, n astiencoder.Node, filled bool) {
 	// Get to
-	to := from.Add(r.period)
+	to := from.Add(time.Duration(atomic.LoadInt64(&r.period)))
+
+	// Transition is in progress
+	if r.transition != nil {
+		if f, n = r.transitionFrame(from, to); f != nil {
+			r.cleanup(to)
+			r.f.NoFill(f, n)
+			return
+		}
+	}
 
 	// If desired node is different from the current node, we check it first
 	if r.desiredNode != nil && r.desiredNode != r.currentNode {
@@ -409,6 +712,96 @@ func (r *RateEnforcer) frame(from time.Time) (f *astiav.Frame, n astiencoder.Nod
 	return
 }
 
+func (r *RateEnforcer) transitionFrame(from, to time.Time) (f *astiav.Frame, n astiencoder.Node) {
+	// Get incoming frame
+	t := r.transition
+	fIn := r.frameForNode(t.n, from, to)
+	if fIn == nil {
+		return
+	}
+
+	// Get outgoing frame
+	var fOut *astiav.Frame
+	if r.currentNode != nil {
+		fOut = r.frameForNode(r.currentNode, from, to)
+	}
+
+	// Transition is starting
+	if t.startAt.IsZero() {
+		// Store start
+		t.startAt = from
+
+		// Emit event
+		r.eh.Emit(astiencoder.Event{
+			Name:    EventNameRateEnforcerSwitchedIn,
+			Payload: t.n,
+			Target:  r,
+		})
+	}
+
+	// Transition is over or there's nothing to blend with
+	alpha := float64(from.Sub(t.startAt)) / float64(t.d)
+	if alpha >= 1 || fOut == nil {
+		if fOut != nil {
+			r.p.put(fOut)
+		}
+		r.desiredNode = t.n
+		r.transition = nil
+		return fIn, t.n
+	}
+
+	// Make sure to close frames
+	defer r.p.put(fIn)
+	defer r.p.put(fOut)
+
+	// Copy outgoing frame
+	f = r.p.get()
+	if err := f.Ref(fOut); err != nil {
+		emitError(r, r.eh, err, "refing frame")
+		r.p.put(f)
+		return nil, nil
+	}
+
+	// Blend frames
+	if err := alphaBlendFrames(f, fIn, alpha); err != nil {
+		emitError(r, r.eh, err, "blending frames")
+		r.p.put(f)
+		return nil, nil
+	}
+	return f, r.currentNode
+}
+
+func alphaBlendFrames(dst, src *astiav.Frame, alpha float64) (err error) {
+	// Formats are different
+	if dst.Width() != src.Width() || dst.Height() != src.Height() || dst.PixelFormat() != src.PixelFormat() {
+		err = errors.New("astilibav: frames have different formats")
+		return
+	}
+
+	// Make sure frame is writable
+	if err = dst.MakeWritable(); err != nil {
+		err = fmt.Errorf("astilibav: making frame writable failed: %w", err)
+		return
+	}
+
+	// Loop through planes
+	dd, sd := dst.Data(), src.Data()
+	dl, sl := dst.Linesize(), src.Linesize()
+	for i := range dd {
+		// Linesizes are different
+		if dl[i] != sl[i] {
+			err = fmt.Errorf("astilibav: linesizes of plane %d are different", i)
+			return
+		}
+
+		// Blend bytes
+		for j := 0; j < len(dd[i]) && j < len(sd[i]); j++ {
+			dd[i][j] = uint8(float64(dd[i][j])*(1-alpha) + float64(sd[i][j])*alpha)
+		}
+	}
+	return
+}
+
 func (r *RateEnforcer) frameForNode(n astiencoder.Node, from, to time.Time) (f *astiav.Frame) {
 	// Get pts reference
 	ptsReference, ok := r.ptsReferences[n]
@@ -420,6 +813,11 @@ func (r *RateEnforcer) frameForNode(n astiencoder.Node, from, to time.Time) (f *
 	ptsMin := ptsReference.ptsFromTime(from)
 	ptsMax := ptsReference.ptsFromTime(to)
 
+	// Slots adapt to incoming frames
+	if r.adaptSlots {
+		return r.adaptedFrameForNode(n, ptsMin, ptsMax)
+	}
+
 	// Loop through frames
 	for idx := range r.frames[n] {
 		if r.frames[n][idx].Pts() >= ptsMin && r.frames[n][idx].Pts() < ptsMax {
@@ -431,6 +829,45 @@ func (r *RateEnforcer) frameForNode(n astiencoder.Node, from, to time.Time) (f *
 	return
 }
 
+// adaptedPTSMin extends the slot span to the observed inter-frame interval when it's bigger than
+// the output period
+func (r *RateEnforcer) adaptedPTSMin(n astiencoder.Node, ptsMin, ptsMax int64) int64 {
+	if i, ok := r.intervals[n]; ok && i.avg > ptsMax-ptsMin {
+		return ptsMax - i.avg
+	}
+	return ptsMin
+}
+
+func (r *RateEnforcer) adaptedFrameForNode(n astiencoder.Node, ptsMin, ptsMax int64) (f *astiav.Frame) {
+	// No interval
+	i, ok := r.intervals[n]
+	if !ok {
+		return
+	}
+
+	// Adapt span so that frames received later than expected don't miss their slot
+	ptsMin = r.adaptedPTSMin(n, ptsMin, ptsMax)
+
+	// Frames are sorted by pts, therefore we look for the most recent frame within the span since
+	// clustered frames would otherwise leave the latest ones for the cleanup. Frames older than the
+	// last dispatched one are ignored so that pts never go backward
+	for idx := len(r.frames[n]) - 1; idx >= 0; idx-- {
+		if pts := r.frames[n][idx].Pts(); pts >= ptsMin && pts < ptsMax && pts > i.dispatchedPTS {
+			// Get frame
+			f = r.frames[n][idx]
+			i.dispatchedPTS = f.Pts()
+
+			// Drop older frames since they can't be dispatched anymore
+			for _, v := range r.frames[n][:idx] {
+				r.p.put(v)
+			}
+			r.frames[n] = r.frames[n][idx+1:]
+			break
+		}
+	}
+	return
+}
+
 func (r *RateEnforcer) cleanup(to time.Time) {
 	// Loop through nodes
 	for n := range r.frames {
@@ -443,6 +880,11 @@ func (r *RateEnforcer) cleanup(to time.Time) {
 		// Get max pts
 		ptsMax := ptsReference.ptsFromTime(to)
 
+		// Frames within the adapted span of the last slot are kept
+		if r.adaptSlots {
+			ptsMax = r.adaptedPTSMin(n, ptsReference.ptsFromTime(to.Add(-time.Duration(atomic.LoadInt64(&r.period)))), ptsMax)
+		}
+
 		// Loop through frames
 		for idx := 0; idx < len(r.frames[n]); idx++ {
 			// PTS is too old
@@ -460,6 +902,12 @@ type RateEnforcerFiller interface {
 	NoFill(*astiav.Frame, astiencoder.Node)
 }
 
+// RateEnforcerFillerResetter is implemented by fillers that need to drop their state when
+// the rate enforcer is reset
+type RateEnforcerFillerResetter interface {
+	Reset()
+}
+
 type previousRateEnforcerFiller struct {
 	eh     *astiencoder.EventHandler
 	f      *astiav.Frame
@@ -499,6 +947,17 @@ func (f *previousRateEnforcerFiller) NoFill(fm *astiav.Frame, n astiencoder.Node
 	}
 }
 
+func (f *previousRateEnforcerFiller) Reset() {
+	// Store
+	f.n = nil
+
+	// Release frame
+	if f.f != nil {
+		f.p.put(f.f)
+		f.f = nil
+	}
+}
+
 type frameRateEnforcerFiller struct {
 	f *astiav.Frame
 }
@@ -536,3 +995,225 @@ func (f *frameRateEnforcerFiller) Fill() (*astiav.Frame, astiencoder.Node) {
 }
 
 func (f *frameRateEnforcerFiller) NoFill(fm *astiav.Frame, n astiencoder.Node) {}
+
+type blankRateEnforcerFiller struct {
+	f       *astiav.Frame
+	ptsStep int64
+}
+
+// NewBlankRateEnforcerFiller creates a filler that fills gaps with a black video frame or a silent
+// audio frame matching the provided context. The frame is allocated once and its PTS is updated
+// on each fill
+func NewBlankRateEnforcerFiller(ctx Context, c *astikit.Closer) (f *blankRateEnforcerFiller, err error) {
+	// Create filler
+	f = &blankRateEnforcerFiller{ptsStep: astiav.RescaleQ(int64(rateEnforcerPeriod(ctx)), nanosecondRational, ctx.TimeBase)}
+
+	// Alloc frame
+	f.f = astiav.AllocFrame()
+
+	// Make sure to free frame
+	defer func(err *error) {
+		if *err != nil {
+			f.f.Free()
+		} else {
+			c.Add(f.f.Free)
+		}
+	}(&err)
+
+	// Switch on media type
+	switch ctx.MediaType {
+	case astiav.MediaTypeAudio:
+		// Update frame
+		f.f.SetChannelLayout(ctx.ChannelLayout)
+		f.f.SetNbSamples(ctx.FrameSize)
+		f.f.SetSampleFormat(ctx.SampleFormat)
+		f.f.SetSampleRate(ctx.SampleRate)
+
+		// Alloc buffer
+		if err = f.f.AllocBuffer(0); err != nil {
+			err = fmt.Errorf("astilibav: allocating buffer failed: %w", err)
+			return
+		}
+
+		// Fill with silence
+		if err = f.f.SamplesFillSilence(); err != nil {
+			err = fmt.Errorf("astilibav: filling samples with silence failed: %w", err)
+			return
+		}
+	case astiav.MediaTypeVideo:
+		// Update frame
+		f.f.SetHeight(ctx.Height)
+		f.f.SetPixelFormat(ctx.PixelFormat)
+		f.f.SetSampleAspectRatio(ctx.SampleAspectRatio)
+		f.f.SetWidth(ctx.Width)
+
+		// Alloc buffer
+		if err = f.f.AllocBuffer(0); err != nil {
+			err = fmt.Errorf("astilibav: allocating buffer failed: %w", err)
+			return
+		}
+
+		// Fill with black
+		if err = f.f.ImageFillBlack(); err != nil {
+			err = fmt.Errorf("astilibav: filling image with black failed: %w", err)
+			return
+		}
+	default:
+		err = fmt.Errorf("astilibav: media type %s is not handled by blank filler", ctx.MediaType)
+		return
+	}
+	return
+}
+
+func (f *blankRateEnforcerFiller) Fill() (*astiav.Frame, astiencoder.Node) {
+	f.f.SetPts(f.f.Pts() + f.ptsStep)
+	return f.f, nil
+}
+
+func (f *blankRateEnforcerFiller) NoFill(fm *astiav.Frame, n astiencoder.Node) {
+	f.f.SetPts(fm.Pts())
+}
+
+type imageRateEnforcerFiller struct {
+	ctx     Context
+	f       *astiav.Frame
+	m       *sync.Mutex // Locks f and next
+	next    *astiav.Frame
+	ptsStep int64
+}
+
+// NewImageRateEnforcerFiller creates a filler that fills video gaps with a still image (e.g. a
+// "technical difficulties" slate) scaled and converted to the provided context's dimensions and
+// pixel format.
+//
+// Fill and NoFill are called from the rate enforcer's tick goroutine whereas SetImage and
+// SetImageFile can be called from any goroutine: the new image is converted right away and is
+// only swapped in on the next fill, so that a frame being dispatched is never modified
+func NewImageRateEnforcerFiller(ctx Context, i image.Image, c *astikit.Closer) (f *imageRateEnforcerFiller, err error) {
+	// Create filler
+	f = &imageRateEnforcerFiller{
+		ctx:     ctx,
+		m:       &sync.Mutex{},
+		ptsStep: astiav.RescaleQ(int64(rateEnforcerPeriod(ctx)), nanosecondRational, ctx.TimeBase),
+	}
+
+	// Convert image
+	if f.f, err = imageToFrame(i, ctx); err != nil {
+		err = fmt.Errorf("astilibav: converting image to frame failed: %w", err)
+		return
+	}
+
+	// Make sure frames are freed
+	c.Add(f.free)
+	return
+}
+
+// NewImageFileRateEnforcerFiller is the same as NewImageRateEnforcerFiller except the image is
+// decoded from a jpeg or png file
+func NewImageFileRateEnforcerFiller(ctx Context, path string, c *astikit.Closer) (f *imageRateEnforcerFiller, err error) {
+	// Decode image
+	var i image.Image
+	if i, err = decodeImageFile(path); err != nil {
+		return
+	}
+	return NewImageRateEnforcerFiller(ctx, i, c)
+}
+
+func decodeImageFile(path string) (i image.Image, err error) {
+	// Open file
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		err = fmt.Errorf("astilibav: opening %s failed: %w", path, err)
+		return
+	}
+	defer f.Close()
+
+	// Decode
+	if i, _, err = image.Decode(f); err != nil {
+		err = fmt.Errorf("astilibav: decoding %s failed: %w", path, err)
+		return
+	}
+	return
+}
+
+func (f *imageRateEnforcerFiller) free() {
+	// Lock
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// Free frames
+	if f.f != nil {
+		f.f.Free()
+		f.f = nil
+	}
+	if f.next != nil {
+		f.next.Free()
+		f.next = nil
+	}
+}
+
+// SetImage replaces the image used to fill gaps. It's safe to call it while the rate enforcer
+// is running
+func (f *imageRateEnforcerFiller) SetImage(i image.Image) (err error) {
+	// Convert image
+	var fm *astiav.Frame
+	if fm, err = imageToFrame(i, f.ctx); err != nil {
+		err = fmt.Errorf("astilibav: converting image to frame failed: %w", err)
+		return
+	}
+
+	// Lock
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// Replace pending frame
+	if f.next != nil {
+		f.next.Free()
+	}
+	f.next = fm
+	return
+}
+
+// SetImageFile is the same as SetImage except the image is decoded from a jpeg or png file
+func (f *imageRateEnforcerFiller) SetImageFile(path string) (err error) {
+	// Decode image
+	var i image.Image
+	if i, err = decodeImageFile(path); err != nil {
+		return
+	}
+	return f.SetImage(i)
+}
+
+func (f *imageRateEnforcerFiller) Fill() (*astiav.Frame, astiencoder.Node) {
+	// Lock
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// Filler has been closed
+	if f.f == nil {
+		return nil, nil
+	}
+
+	// Swap in pending frame
+	// Previous frame has already been dispatched and downstream nodes hold their own reference
+	if f.next != nil {
+		f.next.SetPts(f.f.Pts())
+		f.f.Free()
+		f.f, f.next = f.next, nil
+	}
+
+	// Update pts
+	f.f.SetPts(f.f.Pts() + f.ptsStep)
+	return f.f, nil
+}
+
+func (f *imageRateEnforcerFiller) NoFill(fm *astiav.Frame, n astiencoder.Node) {
+	// Lock
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// Update pts
+	if f.f != nil {
+		f.f.SetPts(fm.Pts())
+	}
+}
@@ -0,0 +1,311 @@
+package astilibav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+var countResampler uint64
+
+// Resampler represents an object capable of converting audio frames' sample rate, sample format
+// and channel layout
+type Resampler struct {
+	*astiencoder.BaseNode
+	c                   *astikit.Chan
+	d                   *frameDispatcher
+	eh                  *astiencoder.EventHandler
+	eos                 *eosTracker
+	inputCtx            Context
+	nextPTS             int64
+	outputCtx           Context
+	p                   *framePool
+	restamper           FrameRestamper
+	src                 *astiav.SoftwareResampleContext
+	statFramesProcessed uint64
+	statFramesReceived  uint64
+}
+
+// ResamplerOptions represents resampler options
+type ResamplerOptions struct {
+	// Dispatcher options, used to choose how frames are dispatched to several handlers
+	Dispatcher FrameDispatcherOptions
+	// Used as timebase when incoming frames have no descriptor
+	InputCtx Context
+	Node     astiencoder.NodeOptions
+	// SampleRate, SampleFormat and ChannelLayout are mandatory.
+	// TimeBase defaults to 1/SampleRate
+	OutputCtx Context
+	Restamper FrameRestamper
+}
+
+// NewResampler creates a new resampler
+func NewResampler(o ResamplerOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (r *Resampler, err error) {
+	// Check output ctx
+	if o.OutputCtx.SampleRate <= 0 || o.OutputCtx.ChannelLayout == 0 {
+		err = errors.New("astilibav: output sample rate and channel layout are mandatory")
+		return
+	}
+
+	// Default timebase
+	if o.OutputCtx.TimeBase.Num() == 0 {
+		o.OutputCtx.TimeBase = astiav.NewRational(1, o.OutputCtx.SampleRate)
+	}
+	o.OutputCtx.MediaType = astiav.MediaTypeAudio
+	o.OutputCtx.Channels = o.OutputCtx.ChannelLayout.NbChannels()
+
+	// Extend node metadata
+	count := atomic.AddUint64(&countResampler, uint64(1))
+	o.Node.Metadata = o.Node.Metadata.Extend(fmt.Sprintf("resampler_%d", count), fmt.Sprintf("Resampler #%d", count), "Resamples", "resampler")
+
+	// Create resampler
+	r = &Resampler{
+		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh:        eh,
+		inputCtx:  o.InputCtx,
+		nextPTS:   astiav.NoPtsValue,
+		outputCtx: o.OutputCtx,
+		restamper: o.Restamper,
+	}
+
+	// Create base node
+	r.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, r, astiencoder.EventTypeToNodeEventName)
+
+	// Create end of stream tracker
+	r.eos = newEOSTracker(r)
+
+	// Create frame pool
+	r.p = newFramePool(r)
+
+	// Create frame dispatcher
+	r.d = newFrameDispatcherWithOptions(r, eh, o.Dispatcher)
+
+	// Alloc software resample context
+	// It's configured based on the first frames it converts
+	r.src = astiav.AllocSoftwareResampleContext()
+
+	// Make sure the software resample context is properly freed
+	r.AddClose(r.src.Free)
+
+	// Add stat options
+	r.addStatOptions()
+	return
+}
+
+type ResamplerStats struct {
+	FramesAllocated uint64
+	FramesDispached uint64
+	FramesProcessed uint64
+	FramesReceived  uint64
+	WorkDuration    time.Duration
+}
+
+func (r *Resampler) Stats() ResamplerStats {
+	return ResamplerStats{
+		FramesAllocated: r.p.stats().framesAllocated,
+		FramesDispached: r.d.stats().framesDispatched,
+		FramesProcessed: atomic.LoadUint64(&r.statFramesProcessed),
+		FramesReceived:  atomic.LoadUint64(&r.statFramesReceived),
+		WorkDuration:    r.c.Stats().WorkDuration,
+	}
+}
+
+func (r *Resampler) addStatOptions() {
+	// Get stats
+	ss := r.c.StatOptions()
+	ss = append(ss, r.d.statOptions()...)
+	ss = append(ss, r.p.statOptions()...)
+	ss = append(ss,
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames coming in per second",
+				Label:       "Incoming rate",
+				Name:        StatNameIncomingRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&r.statFramesReceived),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames processed per second",
+				Label:       "Processed rate",
+				Name:        StatNameProcessedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&r.statFramesProcessed),
+		},
+	)
+
+	// Add stats
+	r.BaseNode.AddStats(ss...)
+}
+
+// OutputCtx returns the output ctx
+func (r *Resampler) OutputCtx() Context {
+	return r.outputCtx
+}
+
+// Connect implements the FrameHandlerConnector interface
+func (r *Resampler) Connect(h FrameHandler) {
+	// Add handler
+	r.d.addHandler(h)
+
+	// Connect nodes
+	astiencoder.ConnectNodes(r, h)
+}
+
+// Disconnect implements the FrameHandlerConnector interface
+func (r *Resampler) Disconnect(h FrameHandler) {
+	// Delete handler
+	r.d.delHandler(h)
+
+	// Disconnect nodes
+	astiencoder.DisconnectNodes(r, h)
+}
+
+// Start starts the resampler
+func (r *Resampler) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
+	r.BaseNode.Start(ctx, t, func(t *astikit.Task) {
+		// Make sure to stop the chan properly
+		defer r.c.Stop()
+
+		// Start chan
+		r.c.Start(r.Context())
+
+		// Chan stops once parents are stopped and all frames have been processed: samples
+		// buffered in the software resample context need to be drained
+		r.flush()
+	})
+}
+
+// HandleEOS implements the EOSHandler interface
+func (r *Resampler) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	r.DoWhenUnclosed(func() {
+		// Add to chan
+		r.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			r.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !r.eos.add(p.Node) {
+					return
+				}
+
+				// Flush
+				r.flush()
+
+				// Dispatch end of stream
+				r.d.dispatchEOS()
+			})
+		})
+	})
+}
+
+// HandleFrame implements the FrameHandler interface
+func (r *Resampler) HandleFrame(p FrameHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	r.DoWhenUnclosed(func() {
+		// Increment received frames
+		atomic.AddUint64(&r.statFramesReceived, 1)
+
+		// Copy frame
+		fm := r.p.get()
+		if err := fm.Ref(p.Frame); err != nil {
+			r.p.put(fm)
+			emitError(r, r.eh, err, "refing frame")
+			return
+		}
+
+		// Add to chan
+		r.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			r.DoWhenUnclosed(func() {
+				// Handle pause
+				defer r.HandlePause()
+
+				// Make sure to close frame
+				defer r.p.put(fm)
+
+				// Increment processed frames
+				atomic.AddUint64(&r.statFramesProcessed, 1)
+
+				// Get timebase
+				timeBase := r.inputCtx.TimeBase
+				if p.Descriptor != nil {
+					timeBase = p.Descriptor.TimeBase()
+				}
+
+				// Update next pts
+				if r.nextPTS == astiav.NoPtsValue && fm.Pts() != astiav.NoPtsValue && timeBase.Num() > 0 {
+					r.nextPTS = astiav.RescaleQ(fm.Pts(), timeBase, r.outputCtx.TimeBase)
+				}
+
+				// Convert
+				r.convert(fm)
+			})
+		})
+	})
+}
+
+func (r *Resampler) newOutputFrame() (f *astiav.Frame) {
+	f = r.p.get()
+	f.SetChannelLayout(r.outputCtx.ChannelLayout)
+	f.SetSampleFormat(r.outputCtx.SampleFormat)
+	f.SetSampleRate(r.outputCtx.SampleRate)
+	return
+}
+
+// convert converts the frame and dispatches the output. If the frame is nil, samples buffered in
+// the software resample context are drained instead. It returns the number of dispatched samples
+func (r *Resampler) convert(src *astiav.Frame) (n int) {
+	// Get output frame
+	// Its buffer is allocated by the software resample context
+	f := r.newOutputFrame()
+	defer r.p.put(f)
+
+	// Convert
+	if err := r.src.ConvertFrame(src, f); err != nil {
+		emitError(r, r.eh, err, "converting frame")
+		return
+	}
+
+	// Nothing to dispatch
+	if n = f.NbSamples(); n == 0 {
+		return
+	}
+
+	// Set pts
+	f.SetPts(r.nextPTS)
+	if r.nextPTS != astiav.NoPtsValue {
+		r.nextPTS += astiav.RescaleQ(int64(n), astiav.NewRational(1, r.outputCtx.SampleRate), r.outputCtx.TimeBase)
+	}
+
+	// Restamp
+	if r.restamper != nil {
+		r.restamper.Restamp(f)
+	}
+
+	// Dispatch frame
+	r.d.dispatch(f, r.outputCtx.Descriptor())
+	return
+}
+
+func (r *Resampler) flush() {
+	// No frame has been processed
+	if atomic.LoadUint64(&r.statFramesProcessed) == 0 {
+		return
+	}
+
+	// Drain delayed samples
+	for r.src.Delay(int64(r.outputCtx.SampleRate)) > 0 {
+		if n := r.convert(nil); n == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package astilibav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+var countScaler uint64
+
+// Scaler represents an object capable of scaling video frames and converting their pixel format.
+// It's lighter than a filterer with a scale filter.
+//
+// If an input frame's dimensions or pixel format differ from the current input ctx, the scale context
+// is recreated and EventNameScalerReconfigured is emitted
+type Scaler struct {
+	*astiencoder.BaseNode
+	c                   *astikit.Chan
+	d                   *frameDispatcher
+	eh                  *astiencoder.EventHandler
+	eos                 *eosTracker
+	flags               astiav.SoftwareScaleContextFlags
+	inputCtx            Context
+	outputCtx           Context
+	p                   *framePool
+	restamper           FrameRestamper
+	ssc                 *astiav.SoftwareScaleContext
+	statFramesProcessed uint64
+	statFramesReceived  uint64
+}
+
+// ScalerOptions represents scaler options
+type ScalerOptions struct {
+	// Dispatcher options, used to choose how frames are dispatched to several handlers
+	Dispatcher FrameDispatcherOptions
+	// Defaults to bilinear
+	Flags astiav.SoftwareScaleContextFlags
+	// Width, Height and PixelFormat are mandatory
+	InputCtx Context
+	Node     astiencoder.NodeOptions
+	// Width, Height and PixelFormat are mandatory
+	OutputCtx Context
+	Restamper FrameRestamper
+}
+
+// NewScaler creates a new scaler
+func NewScaler(o ScalerOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (n *Scaler, err error) {
+	// Check ctxs
+	if o.InputCtx.Width <= 0 || o.InputCtx.Height <= 0 || o.OutputCtx.Width <= 0 || o.OutputCtx.Height <= 0 {
+		err = errors.New("astilibav: input and output dimensions are mandatory")
+		return
+	}
+
+	// Default flags
+	if o.Flags == 0 {
+		o.Flags = astiav.NewSoftwareScaleContextFlags(astiav.SoftwareScaleContextFlagBilinear)
+	}
+	o.OutputCtx.MediaType = astiav.MediaTypeVideo
+
+	// Extend node metadata
+	count := atomic.AddUint64(&countScaler, uint64(1))
+	o.Node.Metadata = o.Node.Metadata.Extend(fmt.Sprintf("scaler_%d", count), fmt.Sprintf("Scaler #%d", count), "Scales", "scaler")
+
+	// Create scaler
+	n = &Scaler{
+		c:         astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh:        eh,
+		flags:     o.Flags,
+		inputCtx:  o.InputCtx,
+		outputCtx: o.OutputCtx,
+		restamper: o.Restamper,
+	}
+
+	// Create base node
+	n.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, n, astiencoder.EventTypeToNodeEventName)
+
+	// Create end of stream tracker
+	n.eos = newEOSTracker(n)
+
+	// Create frame pool
+	n.p = newFramePool(n)
+
+	// Create frame dispatcher
+	n.d = newFrameDispatcherWithOptions(n, eh, o.Dispatcher)
+
+	// Make sure the software scale context is properly freed
+	n.AddClose(n.freeScaleContext)
+
+	// Create software scale context
+	if err = n.createScaleContext(); err != nil {
+		err = fmt.Errorf("astilibav: creating scale context failed: %w", err)
+		return
+	}
+
+	// Add stat options
+	n.addStatOptions()
+	return
+}
+
+func (n *Scaler) createScaleContext() (err error) {
+	n.ssc, err = astiav.CreateSoftwareScaleContext(n.inputCtx.Width, n.inputCtx.Height, n.inputCtx.PixelFormat, n.outputCtx.Width, n.outputCtx.Height, n.outputCtx.PixelFormat, n.flags)
+	return
+}
+
+func (n *Scaler) freeScaleContext() {
+	if n.ssc != nil {
+		n.ssc.Free()
+		n.ssc = nil
+	}
+}
+
+type ScalerStats struct {
+	FramesAllocated uint64
+	FramesDispached uint64
+	FramesProcessed uint64
+	FramesReceived  uint64
+	WorkDuration    time.Duration
+}
+
+func (n *Scaler) Stats() ScalerStats {
+	return ScalerStats{
+		FramesAllocated: n.p.stats().framesAllocated,
+		FramesDispached: n.d.stats().framesDispatched,
+		FramesProcessed: atomic.LoadUint64(&n.statFramesProcessed),
+		FramesReceived:  atomic.LoadUint64(&n.statFramesReceived),
+		WorkDuration:    n.c.Stats().WorkDuration,
+	}
+}
+
+func (n *Scaler) addStatOptions() {
+	// Get stats
+	ss := n.c.StatOptions()
+	ss = append(ss, n.d.statOptions()...)
+	ss = append(ss, n.p.statOptions()...)
+	ss = append(ss,
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames coming in per second",
+				Label:       "Incoming rate",
+				Name:        StatNameIncomingRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&n.statFramesReceived),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames processed per second",
+				Label:       "Processed rate",
+				Name:        StatNameProcessedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&n.statFramesProcessed),
+		},
+	)
+
+	// Add stats
+	n.BaseNode.AddStats(ss...)
+}
+
+// OutputCtx returns the output ctx
+func (n *Scaler) OutputCtx() Context {
+	return n.outputCtx
+}
+
+// Connect implements the FrameHandlerConnector interface
+func (n *Scaler) Connect(h FrameHandler) {
+	// Add handler
+	n.d.addHandler(h)
+
+	// Connect nodes
+	astiencoder.ConnectNodes(n, h)
+}
+
+// Disconnect implements the FrameHandlerConnector interface
+func (n *Scaler) Disconnect(h FrameHandler) {
+	// Delete handler
+	n.d.delHandler(h)
+
+	// Disconnect nodes
+	astiencoder.DisconnectNodes(n, h)
+}
+
+// Start starts the scaler
+func (n *Scaler) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
+	n.BaseNode.Start(ctx, t, func(t *astikit.Task) {
+		// Make sure to stop the chan properly
+		defer n.c.Stop()
+
+		// Start chan
+		n.c.Start(n.Context())
+	})
+}
+
+// HandleEOS implements the EOSHandler interface
+func (n *Scaler) HandleEOS(p EOSHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	n.DoWhenUnclosed(func() {
+		// Add to chan
+		n.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			n.DoWhenUnclosed(func() {
+				// Not all parents have reached end of stream
+				if !n.eos.add(p.Node) {
+					return
+				}
+
+				// Dispatch end of stream
+				n.d.dispatchEOS()
+			})
+		})
+	})
+}
+
+// HandleFrame implements the FrameHandler interface
+func (n *Scaler) HandleFrame(p FrameHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	n.DoWhenUnclosed(func() {
+		// Increment received frames
+		atomic.AddUint64(&n.statFramesReceived, 1)
+
+		// Copy frame
+		fm := n.p.get()
+		if err := fm.Ref(p.Frame); err != nil {
+			n.p.put(fm)
+			emitError(n, n.eh, err, "refing frame")
+			return
+		}
+
+		// Add to chan
+		n.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			n.DoWhenUnclosed(func() {
+				// Handle pause
+				defer n.HandlePause()
+
+				// Make sure to close frame
+				defer n.p.put(fm)
+
+				// Increment processed frames
+				atomic.AddUint64(&n.statFramesProcessed, 1)
+
+				// Input format has changed
+				if fm.Width() != n.inputCtx.Width || fm.Height() != n.inputCtx.Height || fm.PixelFormat() != n.inputCtx.PixelFormat {
+					if err := n.reconfigure(fm); err != nil {
+						emitError(n, n.eh, err, "reconfiguring")
+						return
+					}
+				}
+
+				// Get output frame
+				// Its buffer is allocated by the software scale context
+				f := n.p.get()
+				defer n.p.put(f)
+				f.SetHeight(n.outputCtx.Height)
+				f.SetPixelFormat(n.outputCtx.PixelFormat)
+				f.SetWidth(n.outputCtx.Width)
+
+				// Scale
+				if err := n.ssc.ScaleFrame(fm, f); err != nil {
+					emitError(n, n.eh, err, "scaling frame")
+					return
+				}
+
+				// Copy frame properties
+				f.SetKeyFrame(fm.KeyFrame())
+				f.SetPictureType(fm.PictureType())
+				f.SetPts(fm.Pts())
+				f.SetSampleAspectRatio(fm.SampleAspectRatio())
+
+				// Restamp
+				if n.restamper != nil {
+					n.restamper.Restamp(f)
+				}
+
+				// Dispatch frame
+				n.d.dispatch(f, p.Descriptor)
+			})
+		})
+	})
+}
+
+// ScalerReconfiguration is the payload of EventNameScalerReconfigured
+type ScalerReconfiguration struct {
+	New Context
+	Old Context
+}
+
+func (n *Scaler) reconfigure(fm *astiav.Frame) (err error) {
+	// Update input ctx
+	old := n.inputCtx
+	n.inputCtx.Height = fm.Height()
+	n.inputCtx.PixelFormat = fm.PixelFormat()
+	n.inputCtx.Width = fm.Width()
+
+	// Recreate software scale context
+	n.freeScaleContext()
+	if err = n.createScaleContext(); err != nil {
+		err = fmt.Errorf("astilibav: creating scale context failed: %w", err)
+		return
+	}
+
+	// Emit event
+	n.eh.Emit(astiencoder.Event{
+		Name: EventNameScalerReconfigured,
+		Payload: ScalerReconfiguration{
+			New: n.inputCtx,
+			Old: old,
+		},
+		Target: n,
+	})
+	return
+}
@@ -0,0 +1,347 @@
+package astilibav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/asticode/go-astiav"
+	"github.com/asticode/go-astiencoder"
+	"github.com/asticode/go-astikit"
+)
+
+var countSnapshotter uint64
+
+// Snapshotter represents an object capable of converting the next video frame into an image on
+// request. Frames received while there's no pending request are ignored
+type Snapshotter struct {
+	*astiencoder.BaseNode
+	c                   *astikit.Chan
+	eh                  *astiencoder.EventHandler
+	m                   *sync.Mutex // Locks rs
+	p                   *framePool
+	rs                  []chan image.Image
+	statFramesProcessed uint64
+	statFramesReceived  uint64
+}
+
+// SnapshotterOptions represents snapshotter options
+type SnapshotterOptions struct {
+	Node astiencoder.NodeOptions
+}
+
+// NewSnapshotter creates a new snapshotter
+func NewSnapshotter(o SnapshotterOptions, eh *astiencoder.EventHandler, c *astikit.Closer, s *astiencoder.Stater) (n *Snapshotter) {
+	// Extend node metadata
+	count := atomic.AddUint64(&countSnapshotter, uint64(1))
+	o.Node.Metadata = o.Node.Metadata.Extend(fmt.Sprintf("snapshotter_%d", count), fmt.Sprintf("Snapshotter #%d", count), "Snapshots", "snapshotter")
+
+	// Create snapshotter
+	n = &Snapshotter{
+		c:  astikit.NewChan(astikit.ChanOptions{ProcessAll: true}),
+		eh: eh,
+		m:  &sync.Mutex{},
+	}
+
+	// Create base node
+	n.BaseNode = astiencoder.NewBaseNode(o.Node, c, eh, s, n, astiencoder.EventTypeToNodeEventName)
+
+	// Create frame pool
+	n.p = newFramePool(n)
+
+	// Add stat options
+	n.addStatOptions()
+	return
+}
+
+type SnapshotterStats struct {
+	FramesAllocated uint64
+	FramesProcessed uint64
+	FramesReceived  uint64
+	WorkDuration    time.Duration
+}
+
+func (n *Snapshotter) Stats() SnapshotterStats {
+	return SnapshotterStats{
+		FramesAllocated: n.p.stats().framesAllocated,
+		FramesProcessed: atomic.LoadUint64(&n.statFramesProcessed),
+		FramesReceived:  atomic.LoadUint64(&n.statFramesReceived),
+		WorkDuration:    n.c.Stats().WorkDuration,
+	}
+}
+
+func (n *Snapshotter) addStatOptions() {
+	// Get stats
+	ss := n.c.StatOptions()
+	ss = append(ss, n.p.statOptions()...)
+	ss = append(ss,
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames coming in per second",
+				Label:       "Incoming rate",
+				Name:        StatNameIncomingRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&n.statFramesReceived),
+		},
+		astikit.StatOptions{
+			Metadata: &astikit.StatMetadata{
+				Description: "Number of frames processed per second",
+				Label:       "Processed rate",
+				Name:        StatNameProcessedRate,
+				Unit:        "fps",
+			},
+			Valuer: astikit.NewAtomicUint64RateStat(&n.statFramesProcessed),
+		},
+	)
+
+	// Add stats
+	n.BaseNode.AddStats(ss...)
+}
+
+// Start starts the snapshotter
+func (n *Snapshotter) Start(ctx context.Context, t astiencoder.CreateTaskFunc) {
+	n.BaseNode.Start(ctx, t, func(t *astikit.Task) {
+		// Make sure to stop the chan properly
+		defer n.c.Stop()
+
+		// Make sure pending requests are released
+		defer n.release(nil)
+
+		// Start chan
+		n.c.Start(n.Context())
+	})
+}
+
+// Capture returns a chan receiving the next frame as an image. The chan is closed without
+// receiving any image if the frame can't be converted or if the snapshotter is stopped first
+func (n *Snapshotter) Capture() <-chan image.Image {
+	n.m.Lock()
+	defer n.m.Unlock()
+	c := make(chan image.Image, 1)
+	n.rs = append(n.rs, c)
+	return c
+}
+
+// CaptureTo waits for the next frame and writes it to the writer.
+// Format can be either "jpeg" or "png"
+func (n *Snapshotter) CaptureTo(ctx context.Context, w io.Writer, format string) (err error) {
+	// Get encode func
+	var fn func(w io.Writer, i image.Image) error
+	switch format {
+	case "jpeg":
+		fn = func(w io.Writer, i image.Image) error { return jpeg.Encode(w, i, nil) }
+	case "png":
+		fn = png.Encode
+	default:
+		err = fmt.Errorf("astilibav: format %s is not handled", format)
+		return
+	}
+
+	// Wait for image
+	var i image.Image
+	select {
+	case v, ok := <-n.Capture():
+		if !ok {
+			err = errors.New("astilibav: no image captured")
+			return
+		}
+		i = v
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
+
+	// Encode
+	if err = fn(w, i); err != nil {
+		err = fmt.Errorf("astilibav: encoding %s failed: %w", format, err)
+		return
+	}
+	return
+}
+
+func (n *Snapshotter) hasRequests() bool {
+	n.m.Lock()
+	defer n.m.Unlock()
+	return len(n.rs) > 0
+}
+
+func (n *Snapshotter) release(i image.Image) {
+	// Get requests
+	n.m.Lock()
+	rs := n.rs
+	n.rs = nil
+	n.m.Unlock()
+
+	// Loop through requests
+	for _, r := range rs {
+		if i != nil {
+			r <- i
+		}
+		close(r)
+	}
+}
+
+// HandleFrame implements the FrameHandler interface
+func (n *Snapshotter) HandleFrame(p FrameHandlerPayload) {
+	// Everything executed outside the main loop should be protected from the closer
+	n.DoWhenUnclosed(func() {
+		// Increment received frames
+		atomic.AddUint64(&n.statFramesReceived, 1)
+
+		// No pending requests
+		if !n.hasRequests() {
+			return
+		}
+
+		// Copy frame
+		f := n.p.get()
+		if err := f.Ref(p.Frame); err != nil {
+			n.p.put(f)
+			emitError(n, n.eh, err, "refing frame")
+			return
+		}
+
+		// Add to chan
+		n.c.Add(func() {
+			// Everything executed outside the main loop should be protected from the closer
+			n.DoWhenUnclosed(func() {
+				// Handle pause
+				defer n.HandlePause()
+
+				// Make sure to close frame
+				defer n.p.put(f)
+
+				// Increment processed frames
+				atomic.AddUint64(&n.statFramesProcessed, 1)
+
+				// Requests may have already been served by a previous frame
+				if !n.hasRequests() {
+					return
+				}
+
+				// Convert frame
+				i, err := frameToImage(f)
+				if err != nil {
+					emitError(n, n.eh, err, "converting frame to image")
+				}
+
+				// Release requests
+				n.release(i)
+			})
+		})
+	})
+}
+
+func frameToImage(f *astiav.Frame) (i image.Image, err error) {
+	// Get planes
+	r := image.Rect(0, 0, f.Width(), f.Height())
+	d, l := f.Data(), f.Linesize()
+
+	// Switch on pixel format
+	switch f.PixelFormat() {
+	case astiav.PixelFormatYuv420P, astiav.PixelFormatYuvj420P:
+		v := image.NewYCbCr(r, image.YCbCrSubsampleRatio420)
+		frameCopyPlane(v.Y, v.YStride, d[0], l[0], f.Height())
+		frameCopyPlane(v.Cb, v.CStride, d[1], l[1], (f.Height()+1)/2)
+		frameCopyPlane(v.Cr, v.CStride, d[2], l[2], (f.Height()+1)/2)
+		i = v
+	case astiav.PixelFormatRgba:
+		v := image.NewRGBA(r)
+		frameCopyPlane(v.Pix, v.Stride, d[0], l[0], f.Height())
+		i = v
+	case astiav.PixelFormatRgb24:
+		v := image.NewRGBA(r)
+		for y := 0; y < f.Height(); y++ {
+			for x := 0; x < f.Width() && y*l[0]+x*3+2 < len(d[0]); x++ {
+				copy(v.Pix[y*v.Stride+x*4:], d[0][y*l[0]+x*3:y*l[0]+x*3+3])
+				v.Pix[y*v.Stride+x*4+3] = 0xff
+			}
+		}
+		i = v
+	default:
+		err = fmt.Errorf("astilibav: pixel format %s is not handled", f.PixelFormat())
+	}
+	return
+}
+
+// imageToFrame converts the image to a new frame matching the ctx's dimensions and pixel format
+func imageToFrame(i image.Image, ctx Context) (f *astiav.Frame, err error) {
+	// Check ctx
+	b := i.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || ctx.Width <= 0 || ctx.Height <= 0 {
+		err = errors.New("astilibav: image and ctx dimensions are mandatory")
+		return
+	}
+
+	// Draw image in rgba
+	v := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(v, v.Bounds(), i, b.Min, draw.Src)
+
+	// Alloc source frame
+	src := astiav.AllocFrame()
+	defer src.Free()
+	src.SetHeight(b.Dy())
+	src.SetPixelFormat(astiav.PixelFormatRgba)
+	src.SetWidth(b.Dx())
+	if err = src.AllocBuffer(0); err != nil {
+		err = fmt.Errorf("astilibav: allocating buffer failed: %w", err)
+		return
+	}
+
+	// Copy pixels
+	d, l := src.Data(), src.Linesize()
+	for y := 0; y < b.Dy() && y*l[0] < len(d[0]); y++ {
+		copy(d[0][y*l[0]:], v.Pix[y*v.Stride:y*v.Stride+b.Dx()*4])
+	}
+
+	// Create software scale context
+	ssc, err := astiav.CreateSoftwareScaleContext(b.Dx(), b.Dy(), astiav.PixelFormatRgba, ctx.Width, ctx.Height, ctx.PixelFormat, astiav.NewSoftwareScaleContextFlags(astiav.SoftwareScaleContextFlagBilinear))
+	if err != nil {
+		err = fmt.Errorf("astilibav: creating scale context failed: %w", err)
+		return
+	}
+	defer ssc.Free()
+
+	// Alloc frame
+	// Its buffer is allocated by the software scale context
+	f = astiav.AllocFrame()
+	f.SetHeight(ctx.Height)
+	f.SetPixelFormat(ctx.PixelFormat)
+	f.SetSampleAspectRatio(ctx.SampleAspectRatio)
+	f.SetWidth(ctx.Width)
+
+	// Scale
+	if err = ssc.ScaleFrame(src, f); err != nil {
+		f.Free()
+		f = nil
+		err = fmt.Errorf("astilibav: scaling frame failed: %w", err)
+		return
+	}
+	return
+}
+
+func frameCopyPlane(dst []byte, dstStride int, src []byte, srcStride, height int) {
+	for y := 0; y < height; y++ {
+		// Get row boundaries
+		start := y * srcStride
+		if start >= len(src) {
+			return
+		}
+		end := start + dstStride
+		if end > len(src) {
+			end = len(src)
+		}
+
+		// Copy row
+		copy(dst[y*dstStride:], src[start:end])
+	}
+}
@@ -9,8 +9,37 @@ import (
 type Stream struct {
 	CodecParameters *astiav.CodecParameters
 	Ctx             Context
+	Disposition     astiav.DispositionFlags
 	ID              int
 	Index           int
+	// Read from the "language" metadata tag
+	Language string
+}
+
+func newStream(s *astiav.Stream, ctx Context) *Stream {
+	return &Stream{
+		CodecParameters: s.CodecParameters(),
+		Ctx:             ctx,
+		Disposition:     s.Disposition(),
+		ID:              s.ID(),
+		Index:           s.Index(),
+		Language:        dictionaryToMap(s.Metadata())["language"],
+	}
+}
+
+// String implements the fmt.Stringer interface
+func (s *Stream) String() string {
+	str := fmt.Sprintf("stream #%d (id %d): %s %s", s.Index, s.ID, s.Ctx.MediaType, s.Ctx.CodecID)
+	if s.Language != "" {
+		str += ", language " + s.Language
+	}
+	if s.Disposition.Has(astiav.DispositionFlagDefault) {
+		str += ", default"
+	}
+	if s.Disposition.Has(astiav.DispositionFlagForced) {
+		str += ", forced"
+	}
+	return str
 }
 
 // AddStream adds a stream to the format ctx
@@ -2,8 +2,12 @@ package astiencoder
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/asticode/go-astikit"
 )
@@ -125,6 +129,12 @@ func DisconnectNodes(parent, child Node) {
 type NodeOptions struct {
 	AutoStop *NodeAutoStopOptions
 	Metadata NodeMetadata
+	// By default, panics happening in the node's exec func or in funcs executed through
+	// DoWhenUnclosed are recovered: EventNameNodePanicked is emitted and only the node is stopped.
+	// If true, panics are not recovered and crash the process
+	NoPanicRecovery bool
+	// If > 0, overrides the stater's period when computing the node's stats
+	StatPeriod time.Duration
 }
 
 type NodeAutoStopOptions struct {
@@ -134,6 +144,7 @@ type NodeAutoStopOptions struct {
 
 // BaseNode represents a base node
 type BaseNode struct {
+	busy            int64
 	c               *astikit.Closer
 	cancel          context.CancelFunc
 	cancelPause     context.CancelFunc
@@ -149,6 +160,7 @@ type BaseNode struct {
 	oStop           *sync.Once
 	parents         map[string]Node
 	parentsStarted  map[string]bool
+	pausing         int64
 	s               *Stater
 	ss              []astikit.StatOptions
 	status          string
@@ -177,6 +189,12 @@ func NewBaseNode(o NodeOptions, c *astikit.Closer, eh *EventHandler, s *Stater,
 
 	// Set closer callback
 	n.c.OnClosed(func(err error) {
+		// Surface close errors (e.g. a trailer that couldn't be written) rather than ignoring them
+		if err != nil {
+			eh.Emit(EventError(target, fmt.Errorf("astiencoder: closing node failed: %w", err)))
+		}
+
+		// Emit closed event
 		eh.Emit(Event{
 			Name:   et(EventTypeClosed),
 			Target: target,
@@ -185,10 +203,15 @@ func NewBaseNode(o NodeOptions, c *astikit.Closer, eh *EventHandler, s *Stater,
 	return
 }
 
+// AddClose adds a close func. Close funcs are executed in the reverse order they were added (LIFO),
+// therefore a resource must be added before anything relying on it so that it's freed last
 func (n *BaseNode) AddClose(fn astikit.CloseFunc) {
 	n.c.Add(fn)
 }
 
+// AddCloseWithError adds a close func that can fail. Close funcs are executed in LIFO order and all of
+// them are executed even if one fails: errors are aggregated, returned by Close() and emitted as an
+// error event targeting the node
 func (n *BaseNode) AddCloseWithError(fn astikit.CloseFuncWithError) {
 	n.c.AddWithError(fn)
 }
@@ -202,7 +225,42 @@ func (n *BaseNode) IsClosed() bool {
 }
 
 func (n *BaseNode) DoWhenUnclosed(fn func()) {
-	n.c.Do(fn)
+	atomic.AddInt64(&n.busy, 1)
+	defer atomic.AddInt64(&n.busy, -1)
+	n.c.Do(func() {
+		// Recover from panics
+		if !n.o.NoPanicRecovery {
+			defer n.recoverPanic()
+		}
+		fn()
+	})
+}
+
+// NodePanic is the payload of EventNameNodePanicked
+type NodePanic struct {
+	Stack string
+	Value interface{}
+}
+
+func (n *BaseNode) recoverPanic() {
+	// No panic
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	// Emit event
+	n.eh.Emit(Event{
+		Name: n.et(EventTypePanicked),
+		Payload: NodePanic{
+			Stack: string(debug.Stack()),
+			Value: v,
+		},
+		Target: n.target,
+	})
+
+	// Stop the node
+	n.Stop()
 }
 
 // Context returns the node context
@@ -313,17 +371,26 @@ func (n *BaseNode) Start(ctx context.Context, tc CreateTaskFunc, execFunc BaseNo
 
 				// Add stats
 				n.m.Lock()
-				n.s.AddStats(n.target, n.ss...)
+				n.s.AddStatsWithPeriod(n.target, n.o.StatPeriod, n.ss...)
 				n.m.Unlock()
 			}
 
+			// Recover from panics
+			if !n.o.NoPanicRecovery {
+				defer n.recoverPanic()
+			}
+
 			// Exec func
 			execFunc(t)
 		}()
 	})
 }
 
-// Stop stops the node
+// Stop stops the node.
+// Nodes processing their work through an astikit.Chan create it with ProcessAll set to true: work
+// queued before Stop is called is processed before Start returns, but work added afterwards is
+// dropped. To make sure a node processes everything its parents send, use
+// NodeAutoStopOptions.WhenAllParentsAreStopped instead of stopping it directly
 func (n *BaseNode) Stop() {
 	// Make sure the node can only be stopped once
 	n.oStop.Do(func() {
@@ -414,10 +481,36 @@ func (n *BaseNode) HandlePause() {
 		return
 	}
 
+	// Let the node know its work is blocked by the pause
+	atomic.AddInt64(&n.pausing, 1)
+	defer atomic.AddInt64(&n.pausing, -1)
+
 	// Wait for ctx to be done
 	<-n.ctxPause.Done()
 }
 
+// WaitPaused blocks until the node's in-progress work is either done or blocked by the pause, or
+// until the context is done. It returns right away if the node is not paused
+func (n *BaseNode) WaitPaused(ctx context.Context) error {
+	for {
+		// Status is not paused
+		if n.Status() != StatusPaused {
+			return nil
+		}
+
+		// Everything executed within DoWhenUnclosed is blocked by the pause
+		// Some nodes handle pause outside of DoWhenUnclosed (e.g. in their read loop)
+		if atomic.LoadInt64(&n.busy) <= atomic.LoadInt64(&n.pausing) {
+			return nil
+		}
+
+		// Sleep
+		if err := astikit.Sleep(ctx, 5*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
 // AddChild implements the NodeParent interface
 func (n *BaseNode) AddChild(i Node) {
 	// Lock
@@ -0,0 +1,187 @@
+package astiencoder
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusExporter exposes the latest stats in the Prometheus text format.
+// Each stat becomes a gauge whose name is derived from the stat name and whose "target" label
+// is the name of the node or workflow the stat belongs to
+type PrometheusExporter struct {
+	m  *sync.Mutex // Locks ss
+	ss map[prometheusStatKey]EventStat
+}
+
+type prometheusStatKey struct {
+	name   string
+	target interface{}
+}
+
+// NewPrometheusExporter creates a new Prometheus exporter listening to stats events
+func NewPrometheusExporter(eh *EventHandler) (e *PrometheusExporter) {
+	// Create exporter
+	e = &PrometheusExporter{
+		m:  &sync.Mutex{},
+		ss: make(map[prometheusStatKey]EventStat),
+	}
+
+	// Listen to stats
+	// Stats events may only contain a subset of stats when nodes override the stat period, therefore
+	// values are merged
+	eh.Subscribe(EventNameStats, func(evt Event) {
+		e.m.Lock()
+		defer e.m.Unlock()
+		for _, s := range evt.Payload.([]EventStat) {
+			e.ss[prometheusStatKey{
+				name:   s.Name,
+				target: s.Target,
+			}] = s
+		}
+	})
+	return
+}
+
+type prometheusMetric struct {
+	help    string
+	name    string
+	samples []prometheusSample
+}
+
+type prometheusSample struct {
+	target string
+	value  float64
+}
+
+// ServeHTTP implements the http.Handler interface
+func (e *PrometheusExporter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	// Get stats
+	e.m.Lock()
+	var ss []EventStat
+	for _, s := range e.ss {
+		ss = append(ss, s)
+	}
+	e.m.Unlock()
+
+	// Index metrics
+	ms := make(map[string]*prometheusMetric)
+	add := func(name, help, target string, value float64) {
+		name = prometheusMetricName(name)
+		m, ok := ms[name]
+		if !ok {
+			m = &prometheusMetric{
+				help: help,
+				name: name,
+			}
+			ms[name] = m
+		}
+		m.samples = append(m.samples, prometheusSample{
+			target: target,
+			value:  value,
+		})
+	}
+	for _, s := range ss {
+		// Get target
+		target := newServerStat(s).Target
+
+		// Get help
+		help := s.Description
+		if s.Unit != "" {
+			help += " (" + s.Unit + ")"
+		}
+
+		// Host usage
+		if v, ok := s.Value.(StatValueHostUsage); ok {
+			add(s.Name+"_cpu_process", "Process CPU usage (%)", target, v.CPU.Process)
+			add(s.Name+"_cpu_total", "Total CPU usage (%)", target, v.CPU.Total)
+			add(s.Name+"_memory_resident", "Process resident memory (bytes)", target, float64(v.Memory.Resident))
+			add(s.Name+"_memory_total", "Total memory (bytes)", target, float64(v.Memory.Total))
+			add(s.Name+"_memory_used", "Used memory (bytes)", target, float64(v.Memory.Used))
+			add(s.Name+"_memory_virtual", "Process virtual memory (bytes)", target, float64(v.Memory.Virtual))
+			continue
+		}
+
+		// Get value
+		v, ok := prometheusValue(s.Value)
+		if !ok {
+			continue
+		}
+		add(s.Name, help, target, v)
+	}
+
+	// Sort metrics
+	var names []string
+	for n := range ms {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// Write metrics
+	buf := &bytes.Buffer{}
+	for _, n := range names {
+		m := ms[n]
+		sort.Slice(m.samples, func(i, j int) bool { return m.samples[i].target < m.samples[j].target })
+		fmt.Fprintf(buf, "# HELP %s %s\n", m.name, prometheusEscape(m.help, false))
+		fmt.Fprintf(buf, "# TYPE %s gauge\n", m.name)
+		for _, s := range m.samples {
+			fmt.Fprintf(buf, "%s{target=\"%s\"} %s\n", m.name, prometheusEscape(s.target, true), strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+
+	// Write
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rw.Write(buf.Bytes()) //nolint:errcheck
+}
+
+func prometheusMetricName(i string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, i)
+}
+
+func prometheusEscape(i string, quote bool) string {
+	i = strings.ReplaceAll(i, `\`, `\\`)
+	i = strings.ReplaceAll(i, "\n", `\n`)
+	if quote {
+		i = strings.ReplaceAll(i, `"`, `\"`)
+	}
+	return i
+}
+
+func prometheusValue(i interface{}) (float64, bool) {
+	switch v := i.(type) {
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case time.Duration:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
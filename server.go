@@ -170,20 +170,15 @@ type ServerStat struct {
 	Value       interface{} `json:"value"`
 }
 
-func newServerStat(e EventStat) (s ServerStat) {
-	s = ServerStat{
+func newServerStat(e EventStat) ServerStat {
+	return ServerStat{
 		Description: e.Description,
 		Label:       e.Label,
 		Name:        e.Name,
+		Target:      statTargetName(e.Target),
 		Unit:        e.Unit,
 		Value:       e.Value,
 	}
-	if n, ok := e.Target.(Node); ok {
-		s.Target = n.Metadata().Name
-	} else if w, ok := e.Target.(*Workflow); ok {
-		s.Target = w.Name()
-	}
-	return
 }
 
 type ServerWelcome struct {
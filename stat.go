@@ -29,34 +29,74 @@ type EventStat struct {
 
 // Stater represents an object that can compute and handle stats
 type Stater struct {
-	eh *EventHandler
-	m  *sync.Mutex                           // Locks ts
-	ts map[*astikit.StatMetadata]interface{} // Targets indexed by stats metadata
-	s  *astikit.Stater
+	ctx    context.Context
+	eh     *EventHandler
+	m      *sync.Mutex // Locks ctx, ps, ts and vs
+	period time.Duration
+	ps     map[time.Duration]*astikit.Stater // Staters indexed by period override
+	s      *astikit.Stater
+	ts     map[*astikit.StatMetadata]interface{} // Targets indexed by stats metadata
+	vs     map[*astikit.StatMetadata]interface{} // Last values indexed by stats metadata
 }
 
 // NewStater creates a new stater
 func NewStater(period time.Duration, eh *EventHandler) (s *Stater) {
 	s = &Stater{
-		eh: eh,
-		m:  &sync.Mutex{},
-		ts: make(map[*astikit.StatMetadata]interface{}),
+		eh:     eh,
+		m:      &sync.Mutex{},
+		period: period,
+		ps:     make(map[time.Duration]*astikit.Stater),
+		ts:     make(map[*astikit.StatMetadata]interface{}),
+		vs:     make(map[*astikit.StatMetadata]interface{}),
 	}
-	s.s = astikit.NewStater(astikit.StaterOptions{
+	s.s = s.newStater(period)
+	return
+}
+
+func (s *Stater) newStater(period time.Duration) *astikit.Stater {
+	return astikit.NewStater(astikit.StaterOptions{
 		HandleFunc: s.handle,
 		Period:     period,
 	})
-	return
 }
 
 // AddStats adds stats
 func (s *Stater) AddStats(target interface{}, os ...astikit.StatOptions) {
+	s.AddStatsWithPeriod(target, 0, os...)
+}
+
+// AddStatsWithPeriod adds stats computed with the provided period instead of the stater's period.
+// If period is <= 0, the stater's period is used.
+func (s *Stater) AddStatsWithPeriod(target interface{}, period time.Duration, os ...astikit.StatOptions) {
 	s.m.Lock()
 	defer s.m.Unlock()
 	for _, o := range os {
 		s.ts[o.Metadata] = target
 	}
-	s.s.AddStats(os...)
+	s.stater(period).AddStats(os...)
+}
+
+// Assumes the mutex is locked
+func (s *Stater) stater(period time.Duration) *astikit.Stater {
+	// Default period
+	if period <= 0 || period == s.period {
+		return s.s
+	}
+
+	// Stater already exists
+	if st, ok := s.ps[period]; ok {
+		return st
+	}
+
+	// Create stater
+	st := s.newStater(period)
+	s.ps[period] = st
+
+	// Start stater if the main stater has already been started
+	if s.ctx != nil {
+		go st.Start(s.ctx)
+	}
+	return st
 }
 
 // DelStats deletes stats
@@ -65,15 +105,71 @@ func (s *Stater) DelStats(target interface{}, os ...astikit.StatOptions) {
 	defer s.m.Unlock()
 	for _, o := range os {
 		delete(s.ts, o.Metadata)
+		delete(s.vs, o.Metadata)
 	}
 	s.s.DelStats(os...)
+	for _, st := range s.ps {
+		st.DelStats(os...)
+	}
+}
+
+// Snapshot returns the last computed value of every stat indexed by target name and stat name.
+// Target name is the node name or the workflow name.
+// Values are the ones computed at the end of the last period since reading rate stats on demand
+// would interfere with their periodic computation
+func (s *Stater) Snapshot() (m map[string]map[string]interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	m = make(map[string]map[string]interface{})
+	for md, v := range s.vs {
+		// Get target
+		t, ok := s.ts[md]
+		if !ok {
+			continue
+		}
+		n := statTargetName(t)
+
+		// Add value
+		if _, ok := m[n]; !ok {
+			m[n] = make(map[string]interface{})
+		}
+		m[n][md.Name] = v
+	}
+	return
+}
+
+func statTargetName(t interface{}) string {
+	if n, ok := t.(Node); ok {
+		return n.Metadata().Name
+	} else if w, ok := t.(*Workflow); ok {
+		return w.Name()
+	}
+	return ""
 }
 
 // Start starts the stater
-func (s *Stater) Start(ctx context.Context) { s.s.Start(ctx) }
+func (s *Stater) Start(ctx context.Context) {
+	// Start staters with a period override
+	s.m.Lock()
+	s.ctx = ctx
+	for _, st := range s.ps {
+		go st.Start(ctx)
+	}
+	s.m.Unlock()
+
+	// Start main stater
+	s.s.Start(ctx)
+}
 
 // Stop stops the stater
-func (s *Stater) Stop() { s.s.Stop() }
+func (s *Stater) Stop() {
+	s.m.Lock()
+	for _, st := range s.ps {
+		st.Stop()
+	}
+	s.m.Unlock()
+	s.s.Stop()
+}
 
 func (s *Stater) handle(stats []astikit.StatValue) {
 	// No stats
@@ -87,6 +183,9 @@ func (s *Stater) handle(stats []astikit.StatValue) {
 		// Get target
 		s.m.Lock()
 		t, ok := s.ts[stat.StatMetadata]
+		if ok {
+			s.vs[stat.StatMetadata] = stat.Value
+		}
 		s.m.Unlock()
 
 		// No target
@@ -3,6 +3,8 @@ package astiencoder
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/asticode/go-astikit"
 )
@@ -84,6 +86,98 @@ func (w *Workflow) indexedNodesFunc(ns map[string]Node, children []Node) {
 	}
 }
 
+// Validate makes sure the workflow's nodes don't form a directed cycle, which would deadlock the
+// workflow. The returned error names the nodes involved
+func (w *Workflow) Validate() error {
+	// Sort nodes to make the error deterministic
+	ns := w.nodes()
+	sort.Slice(ns, func(i, j int) bool { return ns[i].Metadata().Name < ns[j].Metadata().Name })
+
+	// Loop through nodes
+	visited := make(map[Node]bool)
+	for _, n := range ns {
+		if path := workflowCycle(n, visited, make(map[Node]bool), nil); len(path) > 0 {
+			var names []string
+			for _, p := range path {
+				names = append(names, p.Metadata().Name)
+			}
+			return fmt.Errorf("astiencoder: cycle detected: %s", strings.Join(names, " -> "))
+		}
+	}
+	return nil
+}
+
+func workflowCycle(n Node, visited, inPath map[Node]bool, path []Node) []Node {
+	// Node is already in the path
+	path = append(path, n)
+	if inPath[n] {
+		// Only keep the cycle
+		for idx, p := range path {
+			if p == n {
+				return path[idx:]
+			}
+		}
+	}
+
+	// Node has already been visited
+	if visited[n] {
+		return nil
+	}
+	visited[n] = true
+
+	// Loop through children
+	inPath[n] = true
+	for _, c := range n.Children() {
+		if cycle := workflowCycle(c, visited, inPath, path); len(cycle) > 0 {
+			return cycle
+		}
+	}
+	inPath[n] = false
+	return nil
+}
+
+// DOT returns the workflow's node graph in the Graphviz DOT format. Nodes are labeled with their
+// metadata label and description, and edges follow parent to child connections
+func (w *Workflow) DOT() string {
+	// Sort nodes to make the output deterministic
+	ns := w.nodes()
+	sort.Slice(ns, func(i, j int) bool { return ns[i].Metadata().Name < ns[j].Metadata().Name })
+
+	// Write header
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "digraph %s {\n", workflowDOTQuote(w.name))
+
+	// Loop through nodes
+	for _, n := range ns {
+		m := n.Metadata()
+		label := m.Label
+		if m.Description != "" {
+			label += "\n" + m.Description
+		}
+		fmt.Fprintf(b, "\t%s [label=%s];\n", workflowDOTQuote(m.Name), workflowDOTQuote(label))
+	}
+
+	// Loop through edges
+	for _, n := range ns {
+		cs := n.Children()
+		sort.Slice(cs, func(i, j int) bool { return cs[i].Metadata().Name < cs[j].Metadata().Name })
+		for _, c := range cs {
+			fmt.Fprintf(b, "\t%s -> %s;\n", workflowDOTQuote(n.Metadata().Name), workflowDOTQuote(c.Metadata().Name))
+		}
+	}
+
+	// Write footer
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func workflowDOTQuote(i string) string {
+	i = strings.ReplaceAll(i, `\`, `\\`)
+	i = strings.ReplaceAll(i, `"`, `\"`)
+	i = strings.ReplaceAll(i, "\n", `\n`)
+	return `"` + i + `"`
+}
+
 // StartNodes starts nodes
 func (w *Workflow) StartNodes(ns ...Node) {
 	for _, n := range ns {
@@ -188,6 +282,33 @@ func (w *Workflow) Pause() {
 	})
 }
 
+type pauseWaiter interface {
+	WaitPaused(ctx context.Context) error
+}
+
+// PauseAndWait pauses the workflow and blocks until every paused node has acknowledged the pause,
+// i.e. its in-progress work is either done or blocked by the pause, or until the context is done.
+// Use Continue to resume the workflow
+func (w *Workflow) PauseAndWait(ctx context.Context) error {
+	// Pause
+	w.Pause()
+
+	// Loop through nodes
+	for _, n := range w.nodes() {
+		// Node can't be waited for
+		v, ok := n.(pauseWaiter)
+		if !ok {
+			continue
+		}
+
+		// Wait
+		if err := v.WaitPaused(ctx); err != nil {
+			return fmt.Errorf("astiencoder: waiting for node %s to be paused failed: %w", n.Metadata().Name, err)
+		}
+	}
+	return nil
+}
+
 // Continue continues the workflow
 func (w *Workflow) Continue() {
 	w.bn.continueFunc(func() {